@@ -0,0 +1,426 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const TAR = "tar"
+const TGZ = "tar.gz"
+const ZIP = "zip"
+
+var ErrUnsafePath = errors.New("unsafe archive path")
+var ErrLimit = errors.New("archive limit exceeded")
+var ErrExists = errors.New("file exists")
+
+// zero values place no limit on extraction
+type Limits struct {
+	MaxBytes int64 // total size of extracted file content
+	MaxFiles int   // count of extracted files and directories
+}
+
+// Force allows existing files to be overwritten
+type Options struct {
+	Limits
+	Force bool
+}
+
+// symlinks and other special entries are counted as Skipped and not extracted or packed
+type Stats struct {
+	Files       int
+	Directories int
+	Skipped     int
+	Bytes       int64
+}
+
+// return the canonical format name, accepting tgz as an alias for tar.gz
+func Format(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case TAR:
+		return TAR, nil
+	case TGZ, "tgz":
+		return TGZ, nil
+	case ZIP:
+		return ZIP, nil
+	}
+	return "", fmt.Errorf("unsupported archive format: %s", format)
+}
+
+func ContentType(format string) string {
+	switch format {
+	case TAR:
+		return "application/x-tar"
+	case TGZ:
+		return "application/gzip"
+	case ZIP:
+		return "application/zip"
+	}
+	return "application/octet-stream"
+}
+
+// write the contents of the directory root to w; entry names are relative to root
+func Pack(w io.Writer, root, format string) (*Stats, error) {
+	format, err := Format(format)
+	if err != nil {
+		return nil, err
+	}
+	fileinfo, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !fileinfo.IsDir() {
+		return nil, fmt.Errorf("not a directory: %s", root)
+	}
+	var add func(name string, info fs.FileInfo, content io.Reader) error
+	var finish func() error
+	switch format {
+	case ZIP:
+		writer := zip.NewWriter(w)
+		add = func(name string, info fs.FileInfo, content io.Reader) error {
+			header, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			if info.IsDir() {
+				header.Name += "/"
+			} else {
+				header.Method = zip.Deflate
+			}
+			out, err := writer.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			if content != nil {
+				_, err = io.Copy(out, content)
+			}
+			return err
+		}
+		finish = writer.Close
+	default:
+		var gz *gzip.Writer
+		if format == TGZ {
+			gz = gzip.NewWriter(w)
+			w = gz
+		}
+		writer := tar.NewWriter(w)
+		add = func(name string, info fs.FileInfo, content io.Reader) error {
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			if info.IsDir() {
+				header.Name += "/"
+			}
+			err = writer.WriteHeader(header)
+			if err != nil {
+				return err
+			}
+			if content != nil {
+				_, err = io.Copy(writer, content)
+			}
+			return err
+		}
+		finish = func() error {
+			err := writer.Close()
+			if err == nil && gz != nil {
+				err = gz.Close()
+			}
+			return err
+		}
+	}
+
+	stats := Stats{}
+	err = filepath.WalkDir(root, func(pathname string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if pathname == root {
+			return nil
+		}
+		name, err := filepath.Rel(root, pathname)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+			stats.Directories++
+			return add(name, info, nil)
+		case entry.Type().IsRegular():
+			file, err := os.Open(pathname)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			stats.Files++
+			stats.Bytes += info.Size()
+			return add(name, info, io.LimitReader(file, info.Size()))
+		}
+		stats.Skipped++
+		return nil
+	})
+	if err != nil {
+		return &stats, err
+	}
+	return &stats, finish()
+}
+
+// an archive entry being extracted
+type entry struct {
+	name    string
+	isDir   bool
+	regular bool
+	mode    fs.FileMode
+	modTime time.Time
+	open    func() (io.ReadCloser, error)
+}
+
+// extract the archive read from r under the directory root, creating it if necessary
+func Extract(r io.Reader, root, format string, options Options) (*Stats, error) {
+	format, err := Format(format)
+	if err != nil {
+		return nil, err
+	}
+	err = os.MkdirAll(root, 0755)
+	if err != nil {
+		return nil, err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	x := extractor{root: root, options: options}
+	switch format {
+	case ZIP:
+		err = x.extractZip(r)
+	case TGZ:
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(r)
+		if err == nil {
+			defer gz.Close()
+			err = x.extractTar(gz)
+		}
+	default:
+		err = x.extractTar(r)
+	}
+	return &x.stats, err
+}
+
+type extractor struct {
+	root    string
+	options Options
+	stats   Stats
+}
+
+func (x *extractor) extractTar(r io.Reader) error {
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		err = x.extract(&entry{
+			name:    header.Name,
+			isDir:   header.Typeflag == tar.TypeDir,
+			regular: header.Typeflag == tar.TypeReg,
+			mode:    header.FileInfo().Mode(),
+			modTime: header.ModTime,
+			open: func() (io.ReadCloser, error) {
+				return io.NopCloser(reader), nil
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// zip archives are read from a temporary file since the directory is at the end
+func (x *extractor) extractZip(r io.Reader) error {
+	file, err := os.CreateTemp("", "winexec-archive-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if x.options.MaxBytes > 0 {
+		r = io.LimitReader(r, x.options.MaxBytes+1)
+	}
+	size, err := io.Copy(file, r)
+	if err != nil {
+		return err
+	}
+	if x.options.MaxBytes > 0 && size > x.options.MaxBytes {
+		return fmt.Errorf("%w: archive exceeds %d bytes", ErrLimit, x.options.MaxBytes)
+	}
+	reader, err := zip.NewReader(file, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range reader.File {
+		mode := f.Mode()
+		err := x.extract(&entry{
+			name:    f.Name,
+			isDir:   mode.IsDir(),
+			regular: mode.IsRegular(),
+			mode:    mode,
+			modTime: f.Modified,
+			open:    f.Open,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// return the local pathname for an entry name, rejecting names that leave the root
+func (x *extractor) target(name string) (string, error) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	// a colon on windows could name a drive or an alternate data stream
+	if strings.HasPrefix(name, "/") || filepath.VolumeName(name) != "" || (runtime.GOOS == "windows" && strings.Contains(name, ":")) {
+		return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+	}
+	for _, element := range strings.Split(name, "/") {
+		if element == ".." {
+			return "", fmt.Errorf("%w: %s", ErrUnsafePath, name)
+		}
+	}
+	name = path.Clean(name)
+	if name == "." {
+		return x.root, nil
+	}
+	return filepath.Join(x.root, filepath.FromSlash(name)), nil
+}
+
+// reject a directory that resolves outside the root through an existing symlink;
+// the check is made on the nearest existing ancestor before anything is created
+func (x *extractor) checkDir(pathname string) error {
+	existing := pathname
+	for {
+		_, err := os.Lstat(existing)
+		if err == nil {
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		existing = filepath.Dir(existing)
+	}
+	dir, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return err
+	}
+	if dir != x.root && !strings.HasPrefix(dir, x.root+string(filepath.Separator)) {
+		return fmt.Errorf("%w: %s resolves outside %s", ErrUnsafePath, pathname, x.root)
+	}
+	return nil
+}
+
+func (x *extractor) count() error {
+	if x.options.MaxFiles > 0 && x.stats.Files+x.stats.Directories >= x.options.MaxFiles {
+		return fmt.Errorf("%w: more than %d entries", ErrLimit, x.options.MaxFiles)
+	}
+	return nil
+}
+
+func (x *extractor) extract(e *entry) error {
+	pathname, err := x.target(e.name)
+	if err != nil {
+		return err
+	}
+	if !e.isDir && !e.regular {
+		x.stats.Skipped++
+		return nil
+	}
+	err = x.count()
+	if err != nil {
+		return err
+	}
+	if e.isDir {
+		err = x.checkDir(pathname)
+		if err != nil {
+			return err
+		}
+		err = os.MkdirAll(pathname, 0755)
+		if err != nil {
+			return err
+		}
+		x.stats.Directories++
+		return nil
+	}
+	err = x.checkDir(filepath.Dir(pathname))
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(pathname), 0755)
+	if err != nil {
+		return err
+	}
+	fileinfo, err := os.Lstat(pathname)
+	if err == nil {
+		if !x.options.Force || !fileinfo.Mode().IsRegular() {
+			return fmt.Errorf("%w: %s", ErrExists, pathname)
+		}
+	}
+	content, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	mode := e.mode.Perm()
+	if mode == 0 {
+		mode = 0644
+	}
+	file, err := os.OpenFile(pathname, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	var reader io.Reader = content
+	if x.options.MaxBytes > 0 {
+		reader = io.LimitReader(content, x.options.MaxBytes-x.stats.Bytes+1)
+	}
+	count, err := io.Copy(file, reader)
+	x.stats.Bytes += count
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	if x.options.MaxBytes > 0 && x.stats.Bytes > x.options.MaxBytes {
+		os.Remove(pathname)
+		return fmt.Errorf("%w: more than %d bytes", ErrLimit, x.options.MaxBytes)
+	}
+	x.stats.Files++
+	if !e.modTime.IsZero() {
+		return os.Chtimes(pathname, time.Time{}, e.modTime)
+	}
+	return nil
+}
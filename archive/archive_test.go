@@ -0,0 +1,116 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestTree(t *testing.T) string {
+	root := t.TempDir()
+	require.Nil(t, os.MkdirAll(filepath.Join(root, "sub", "empty"), 0700))
+	require.Nil(t, os.WriteFile(filepath.Join(root, "a.txt"), []byte("alpha"), 0640))
+	require.Nil(t, os.WriteFile(filepath.Join(root, "sub", "b.txt"), bytes.Repeat([]byte("b"), 1000), 0600))
+	require.Nil(t, os.Symlink("a.txt", filepath.Join(root, "link")))
+	return root
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := writeTestTree(t)
+	timestamp := time.Date(2022, 2, 2, 2, 2, 2, 0, time.UTC)
+	require.Nil(t, os.Chtimes(filepath.Join(src, "a.txt"), timestamp, timestamp))
+	for _, format := range []string{TAR, "tgz", ZIP} {
+		var buf bytes.Buffer
+		packed, err := Pack(&buf, src, format)
+		require.Nil(t, err, format)
+		require.Equal(t, Stats{Files: 2, Directories: 2, Skipped: 1, Bytes: 1005}, *packed)
+
+		dst := filepath.Join(t.TempDir(), "dst")
+		extracted, err := Extract(bytes.NewReader(buf.Bytes()), dst, format, Options{})
+		require.Nil(t, err, format)
+		require.Equal(t, Stats{Files: 2, Directories: 2, Bytes: 1005}, *extracted)
+		data, err := os.ReadFile(filepath.Join(dst, "sub", "b.txt"))
+		require.Nil(t, err)
+		require.Len(t, data, 1000)
+		require.DirExists(t, filepath.Join(dst, "sub", "empty"))
+		fileinfo, err := os.Stat(filepath.Join(dst, "a.txt"))
+		require.Nil(t, err)
+		require.True(t, timestamp.Equal(fileinfo.ModTime()))
+		require.Equal(t, os.FileMode(0640), fileinfo.Mode().Perm())
+
+		// existing files are only replaced with Force
+		_, err = Extract(bytes.NewReader(buf.Bytes()), dst, format, Options{})
+		require.True(t, errors.Is(err, ErrExists), format)
+		_, err = Extract(bytes.NewReader(buf.Bytes()), dst, format, Options{Force: true})
+		require.Nil(t, err, format)
+	}
+	_, err := Pack(&bytes.Buffer{}, src, "rar")
+	require.NotNil(t, err)
+}
+
+func tarOf(t *testing.T, headers ...*tar.Header) []byte {
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	for _, header := range headers {
+		if header.Typeflag == 0 {
+			header.Typeflag = tar.TypeReg
+		}
+		require.Nil(t, writer.WriteHeader(header))
+		if header.Typeflag == tar.TypeReg {
+			_, err := writer.Write(bytes.Repeat([]byte("x"), int(header.Size)))
+			require.Nil(t, err)
+		}
+	}
+	require.Nil(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestUnsafePaths(t *testing.T) {
+	for _, name := range []string{"../escape.txt", "sub/../../escape.txt", "/etc/escape.txt", `..\escape.txt`} {
+		dst := filepath.Join(t.TempDir(), "dst")
+		_, err := Extract(bytes.NewReader(tarOf(t, &tar.Header{Name: name, Size: 1, Mode: 0600})), dst, TAR, Options{})
+		require.True(t, errors.Is(err, ErrUnsafePath), name)
+		require.NoFileExists(t, filepath.Join(filepath.Dir(dst), "escape.txt"))
+	}
+
+	// symlink entries are skipped, and existing symlinks cannot be used to leave the root
+	outside := t.TempDir()
+	dst := t.TempDir()
+	require.Nil(t, os.Symlink(outside, filepath.Join(dst, "out")))
+	stats, err := Extract(bytes.NewReader(tarOf(t, &tar.Header{Name: "evil", Typeflag: tar.TypeSymlink, Linkname: outside})), dst, TAR, Options{})
+	require.Nil(t, err)
+	require.Equal(t, 1, stats.Skipped)
+	_, err = Extract(bytes.NewReader(tarOf(t, &tar.Header{Name: "out/x.txt", Size: 1, Mode: 0600})), dst, TAR, Options{})
+	require.True(t, errors.Is(err, ErrUnsafePath))
+	_, err = Extract(bytes.NewReader(tarOf(t, &tar.Header{Name: "out/new/", Typeflag: tar.TypeDir, Mode: 0700})), dst, TAR, Options{})
+	require.True(t, errors.Is(err, ErrUnsafePath))
+	entries, err := os.ReadDir(outside)
+	require.Nil(t, err)
+	require.Empty(t, entries)
+}
+
+func TestLimits(t *testing.T) {
+	archive := tarOf(t, &tar.Header{Name: "a", Size: 600, Mode: 0600}, &tar.Header{Name: "b", Size: 600, Mode: 0600})
+	dst := t.TempDir()
+	_, err := Extract(bytes.NewReader(archive), dst, TAR, Options{Limits: Limits{MaxBytes: 1000}})
+	require.True(t, errors.Is(err, ErrLimit))
+	require.NoFileExists(t, filepath.Join(dst, "b"))
+
+	_, err = Extract(bytes.NewReader(archive), t.TempDir(), TAR, Options{Limits: Limits{MaxFiles: 1}})
+	require.True(t, errors.Is(err, ErrLimit))
+
+	stats, err := Extract(bytes.NewReader(archive), t.TempDir(), TAR, Options{Limits: Limits{MaxBytes: 1200, MaxFiles: 2}})
+	require.Nil(t, err)
+	require.Equal(t, int64(1200), stats.Bytes)
+
+	var buf bytes.Buffer
+	_, err = Pack(&buf, writeTestTree(t), ZIP)
+	require.Nil(t, err)
+	_, err = Extract(bytes.NewReader(buf.Bytes()), t.TempDir(), ZIP, Options{Limits: Limits{MaxBytes: 100}})
+	require.True(t, errors.Is(err, ErrLimit))
+}
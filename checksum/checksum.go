@@ -0,0 +1,190 @@
+package checksum
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const SHA256 = "sha256"
+const SHA512 = "sha512"
+
+// MD5 and SHA1 are provided only for comparison with legacy manifests
+const MD5 = "md5"
+const SHA1 = "sha1"
+
+var ErrMismatch = errors.New("checksum mismatch")
+
+// Hasher computes several digests of the data written to it
+type Hasher struct {
+	hashes map[string]hash.Hash
+}
+
+func newHash(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case SHA256:
+		return sha256.New(), nil
+	case SHA512:
+		return sha512.New(), nil
+	case MD5:
+		return md5.New(), nil
+	case SHA1:
+		return sha1.New(), nil
+	}
+	return nil, fmt.Errorf("unsupported hash algorithm: %s", algorithm)
+}
+
+// with no algorithms, the Hasher computes SHA256 and SHA512
+func New(algorithms ...string) (*Hasher, error) {
+	if len(algorithms) == 0 {
+		algorithms = []string{SHA256, SHA512}
+	}
+	h := Hasher{hashes: make(map[string]hash.Hash)}
+	for _, algorithm := range algorithms {
+		hash, err := newHash(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		h.hashes[strings.ToLower(algorithm)] = hash
+	}
+	return &h, nil
+}
+
+func (h *Hasher) Write(data []byte) (int, error) {
+	for _, hash := range h.hashes {
+		hash.Write(data)
+	}
+	return len(data), nil
+}
+
+// return the hex digest for algorithm, or an empty string if it is not being computed
+func (h *Hasher) Sum(algorithm string) string {
+	hash, ok := h.hashes[strings.ToLower(algorithm)]
+	if !ok {
+		return ""
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (h *Hasher) Sums() map[string]string {
+	sums := make(map[string]string)
+	for algorithm := range h.hashes {
+		sums[algorithm] = h.Sum(algorithm)
+	}
+	return sums
+}
+
+// return an error if expected is set and does not match the computed digest
+func (h *Hasher) Verify(algorithm, expected string) error {
+	if expected == "" {
+		return nil
+	}
+	actual := h.Sum(algorithm)
+	if actual == "" {
+		return fmt.Errorf("%s digest not computed", algorithm)
+	}
+	if !strings.EqualFold(actual, strings.TrimSpace(expected)) {
+		return fmt.Errorf("%s %w: expected %s, computed %s", algorithm, ErrMismatch, expected, actual)
+	}
+	return nil
+}
+
+// verify both digests, ignoring those that are not set
+func (h *Hasher) VerifyAll(sha256, sha512 string) error {
+	err := h.Verify(SHA256, sha256)
+	if err != nil {
+		return err
+	}
+	return h.Verify(SHA512, sha512)
+}
+
+func File(pathname string, algorithms ...string) (*Hasher, error) {
+	h, err := New(algorithms...)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(pathname)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	_, err = io.Copy(h, file)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// FileSums holds the digests of a file with its size and modification time
+type FileSums struct {
+	Size    int64
+	ModTime time.Time
+	Sums    map[string]string
+}
+
+// hash a file, keyed by its name, or every regular file below a directory, keyed by
+// slash separated relative pathname; symlinks are not followed
+func Path(pathname string, algorithms ...string) (map[string]FileSums, error) {
+	files := make(map[string]FileSums)
+	fileinfo, err := os.Stat(pathname)
+	if err != nil {
+		return nil, err
+	}
+	if !fileinfo.IsDir() {
+		hash, err := fileHash(pathname, fileinfo, algorithms)
+		if err != nil {
+			return nil, err
+		}
+		files[fileinfo.Name()] = *hash
+		return files, nil
+	}
+	err = filepath.WalkDir(pathname, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		fileinfo, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		hash, err := fileHash(path, fileinfo, algorithms)
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(pathname, path)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(relative)] = *hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func fileHash(pathname string, fileinfo fs.FileInfo, algorithms []string) (*FileSums, error) {
+	hasher, err := File(pathname, algorithms...)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSums{
+		Size:    fileinfo.Size(),
+		ModTime: fileinfo.ModTime(),
+		Sums:    hasher.Sums(),
+	}, nil
+}
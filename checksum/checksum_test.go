@@ -0,0 +1,39 @@
+package checksum
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	h, err := New()
+	require.Nil(t, err)
+	h.Write([]byte("abc"))
+	require.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", h.Sum(SHA256))
+	require.Equal(t, "ddaf35a193617abacc417349ae20413112e6fa4e89a97ea20a9eeee64b55d39a2192992a274fc1a836ba3c23a3feebbd454d4423643ce80e2a9ac94fa54ca49f", h.Sum(SHA512))
+	require.Nil(t, h.VerifyAll("BA7816BF8F01CFEA414140DE5DAE2223B00361A396177A9CB410FF61F20015AD", ""))
+	require.NotNil(t, h.Verify(SHA256, strings.Repeat("0", 64)))
+	require.Nil(t, h.Verify(SHA256, ""))
+
+	legacy, err := New(MD5, "SHA1")
+	require.Nil(t, err)
+	legacy.Write([]byte("abc"))
+	require.Equal(t, map[string]string{
+		MD5:  "900150983cd24fb0d6963f7d28e17f72",
+		SHA1: "a9993e364706816aba3e25717850c26c9cd0d89d",
+	}, legacy.Sums())
+
+	_, err = New("crc32")
+	require.NotNil(t, err)
+
+	pathname := filepath.Join(t.TempDir(), "abc.txt")
+	require.Nil(t, os.WriteFile(pathname, []byte("abc"), 0600))
+	f, err := File(pathname, SHA256)
+	require.Nil(t, err)
+	require.Equal(t, h.Sum(SHA256), f.Sum(SHA256))
+	require.Equal(t, "", f.Sum(SHA512))
+	require.NotNil(t, f.Verify(SHA512, h.Sum(SHA512)))
+}
@@ -0,0 +1,424 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"github.com/rstms/winexec/message"
+	"github.com/spf13/afero"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+var errBadDescriptor = errors.New("bad file descriptor")
+var errIsDirectory = errors.New("is a directory")
+var errNotDirectory = errors.New("not a directory")
+var errWriteAtInAppendMode = errors.New("invalid use of WriteAt on file opened with O_APPEND")
+
+// AferoFs is an afero.Fs backed by a WinexecClient; names are remote pathnames in any
+// form accepted by ospath, and errors for missing, existing and inaccessible files are
+// *fs.PathError values that os.IsNotExist, os.IsExist and os.IsPermission recognize
+type AferoFs struct {
+	client *WinexecClient
+}
+
+var _ afero.Fs = (*AferoFs)(nil)
+var _ afero.Lstater = (*AferoFs)(nil)
+
+func (c *WinexecClient) AferoFs() *AferoFs {
+	return &AferoFs{client: c}
+}
+
+func (a *AferoFs) Name() string {
+	return "WinexecFs"
+}
+
+func (a *AferoFs) Create(name string) (afero.File, error) {
+	return a.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+func (a *AferoFs) Open(name string) (afero.File, error) {
+	return a.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// the file is opened on the server to apply the flags, but no handle is kept open there
+func (a *AferoFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	access := flag & (os.O_WRONLY | os.O_RDWR)
+	request := message.FileOpenRequest{
+		Pathname:  name,
+		Read:      access != os.O_WRONLY,
+		Write:     access != os.O_RDONLY,
+		Create:    flag&os.O_CREATE != 0,
+		Exclusive: flag&os.O_EXCL != 0,
+		Truncate:  flag&os.O_TRUNC != 0,
+		Mode:      perm,
+	}
+	stat, err := a.client.openFile(&request)
+	if err != nil {
+		return nil, err
+	}
+	file := aferoFile{
+		client:   a.client,
+		name:     name,
+		stat:     *stat,
+		readable: request.Read,
+		writable: request.Write,
+		append:   flag&os.O_APPEND != 0,
+		size:     stat.Size,
+	}
+	return &file, nil
+}
+
+func (a *AferoFs) Mkdir(name string, perm os.FileMode) error {
+	return a.client.Mkdir(name, perm)
+}
+
+// an existing directory is not an error
+func (a *AferoFs) MkdirAll(name string, perm os.FileMode) error {
+	info, err := a.Stat(name)
+	if err == nil {
+		if info.IsDir() {
+			return nil
+		}
+		return &fs.PathError{Op: "mkdir", Path: name, Err: errNotDirectory}
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return a.client.MkdirAll(name, perm)
+}
+
+func (a *AferoFs) Remove(name string) error {
+	return a.client.Remove(name)
+}
+
+// a missing path is not an error
+func (a *AferoFs) RemoveAll(name string) error {
+	return a.client.remove(name, true)
+}
+
+// an existing file at newname is replaced, as with os.Rename
+func (a *AferoFs) Rename(oldname, newname string) error {
+	return a.client.Rename(newname, oldname, true)
+}
+
+// symlinks are followed
+func (a *AferoFs) Stat(name string) (os.FileInfo, error) {
+	stat, err := a.client.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if stat.Mode&fs.ModeSymlink != 0 {
+		stat, err = a.client.openFile(&message.FileOpenRequest{Pathname: name, Read: true})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &fileInfo{stat: *stat}, nil
+}
+
+func (a *AferoFs) LstatIfPossible(name string) (os.FileInfo, bool, error) {
+	stat, err := a.client.Stat(name)
+	if err != nil {
+		return nil, true, err
+	}
+	return &fileInfo{stat: *stat}, true, nil
+}
+
+func (a *AferoFs) Chmod(name string, mode os.FileMode) error {
+	return a.client.Chmod(name, mode)
+}
+
+func (a *AferoFs) Chown(name string, uid, gid int) error {
+	return a.client.Chown(name, uid, gid)
+}
+
+func (a *AferoFs) Chtimes(name string, atime, mtime time.Time) error {
+	return a.client.Chtimes(name, atime, mtime)
+}
+
+// reads are ranged downloads, sequential reads sharing one stream until the offset
+// changes; writes are buffered while contiguous and sent as ranged writes when the
+// buffer reaches UploadChunkSize, or before any read, truncate, stat, sync or close
+type aferoFile struct {
+	client       *WinexecClient
+	name         string
+	stat         message.FileStat
+	readable     bool
+	writable     bool
+	append       bool
+	offset       int64
+	size         int64
+	reader       io.ReadCloser
+	buffer       []byte
+	bufferOffset int64
+	entries      []fs.DirEntry
+	loaded       bool
+	closed       bool
+}
+
+func (f *aferoFile) Name() string {
+	return f.name
+}
+
+func (f *aferoFile) check(op string, access bool) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	if !access {
+		return &fs.PathError{Op: op, Path: f.name, Err: errBadDescriptor}
+	}
+	if f.stat.Mode.IsDir() && op != "seek" && op != "stat" && op != "sync" {
+		return &fs.PathError{Op: op, Path: f.name, Err: errIsDirectory}
+	}
+	return nil
+}
+
+func (f *aferoFile) closeReader() {
+	if f.reader != nil {
+		f.reader.Close()
+		f.reader = nil
+	}
+}
+
+func (f *aferoFile) flush() error {
+	if len(f.buffer) == 0 {
+		return nil
+	}
+	_, err := f.client.WriteAt(f.name, f.bufferOffset, bytes.NewReader(f.buffer))
+	f.buffer = f.buffer[:0]
+	return err
+}
+
+func (f *aferoFile) Read(data []byte) (int, error) {
+	err := f.check("read", f.readable)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	err = f.flush()
+	if err != nil {
+		return 0, err
+	}
+	if f.reader == nil {
+		reader, err := f.client.openRange(f.name, f.offset, 0)
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+		f.reader = reader
+	}
+	count, err := f.reader.Read(data)
+	f.offset += int64(count)
+	if err == io.EOF {
+		f.closeReader()
+	}
+	return count, err
+}
+
+func (f *aferoFile) ReadAt(data []byte, offset int64) (int, error) {
+	err := f.check("read", f.readable)
+	if err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	err = f.flush()
+	if err != nil {
+		return 0, err
+	}
+	reader, err := f.client.openRange(f.name, offset, int64(len(data)))
+	if err == io.EOF {
+		return 0, io.EOF
+	}
+	if err != nil {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+	defer reader.Close()
+	count, err := io.ReadFull(reader, data)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return count, err
+}
+
+// add data at offset to the write buffer, sending the buffer first if data does not follow it
+func (f *aferoFile) write(data []byte, offset int64) (int, error) {
+	f.closeReader()
+	if len(f.buffer) > 0 && offset != f.bufferOffset+int64(len(f.buffer)) {
+		err := f.flush()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(f.buffer) == 0 {
+		f.bufferOffset = offset
+	}
+	f.buffer = append(f.buffer, data...)
+	f.size = max(f.size, offset+int64(len(data)))
+	if int64(len(f.buffer)) >= f.client.UploadChunkSize {
+		err := f.flush()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (f *aferoFile) Write(data []byte) (int, error) {
+	err := f.check("write", f.writable)
+	if err != nil {
+		return 0, err
+	}
+	if f.append {
+		f.offset = f.size
+	}
+	count, err := f.write(data, f.offset)
+	f.offset += int64(count)
+	return count, err
+}
+
+func (f *aferoFile) WriteAt(data []byte, offset int64) (int, error) {
+	err := f.check("write", f.writable)
+	if err != nil {
+		return 0, err
+	}
+	if f.append {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: errWriteAtInAppendMode}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: fs.ErrInvalid}
+	}
+	return f.write(data, offset)
+}
+
+func (f *aferoFile) WriteString(s string) (int, error) {
+	return f.Write([]byte(s))
+}
+
+func (f *aferoFile) Seek(offset int64, whence int) (int64, error) {
+	err := f.check("seek", true)
+	if err != nil {
+		return 0, err
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 || whence < io.SeekStart || whence > io.SeekEnd {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset != f.offset {
+		f.closeReader()
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *aferoFile) Truncate(size int64) error {
+	err := f.check("truncate", f.writable)
+	if err != nil {
+		return err
+	}
+	if size < 0 {
+		return &fs.PathError{Op: "truncate", Path: f.name, Err: fs.ErrInvalid}
+	}
+	err = f.flush()
+	if err != nil {
+		return err
+	}
+	f.closeReader()
+	err = f.client.Truncate(f.name, size)
+	if err != nil {
+		return err
+	}
+	f.size = size
+	return nil
+}
+
+func (f *aferoFile) Sync() error {
+	err := f.check("sync", true)
+	if err != nil {
+		return err
+	}
+	return f.flush()
+}
+
+func (f *aferoFile) Stat() (os.FileInfo, error) {
+	err := f.check("stat", true)
+	if err != nil {
+		return nil, err
+	}
+	err = f.flush()
+	if err != nil {
+		return nil, err
+	}
+	stat, err := f.client.openFile(&message.FileOpenRequest{Pathname: f.name, Read: true})
+	if err != nil {
+		return nil, err
+	}
+	f.stat = *stat
+	f.size = stat.Size
+	return &fileInfo{stat: *stat}, nil
+}
+
+// as with os.File, a positive count returns io.EOF when no entries remain, and
+// otherwise all remaining entries are returned
+func (f *aferoFile) Readdir(count int) ([]os.FileInfo, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: fs.ErrClosed}
+	}
+	if !f.stat.Mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: errNotDirectory}
+	}
+	if !f.loaded {
+		entries, err := f.client.readDir(f.name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: err}
+		}
+		f.entries = entries
+		f.loaded = true
+	}
+	if count > 0 && len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count <= 0 || count > len(f.entries) {
+		count = len(f.entries)
+	}
+	infos := make([]os.FileInfo, count)
+	for i, entry := range f.entries[:count] {
+		infos[i], _ = entry.Info()
+	}
+	f.entries = f.entries[count:]
+	return infos, nil
+}
+
+func (f *aferoFile) Readdirnames(count int) ([]string, error) {
+	infos, err := f.Readdir(count)
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return names, err
+}
+
+// buffered writes are sent before the file is closed
+func (f *aferoFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	f.closeReader()
+	return f.flush()
+}
@@ -0,0 +1,110 @@
+package client_test
+
+import (
+	"github.com/rstms/winexec/wintest"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// the same operations are run against the local disk and the server, which must agree
+func TestAferoFs(t *testing.T) {
+	h := wintest.New(t)
+	c := h.Client
+	c.UploadChunkSize = 8
+	testAferoFs(t, afero.NewOsFs(), t.TempDir())
+	testAferoFs(t, c.AferoFs(), h.Dir)
+}
+
+func testAferoFs(t *testing.T, fsys afero.Fs, root string) {
+	name := filepath.Join(root, "file.txt")
+	file, err := fsys.Create(name)
+	require.Nil(t, err)
+	count, err := file.WriteString("hello, world\n")
+	require.Nil(t, err)
+	require.Equal(t, 13, count)
+	_, err = file.Seek(7, io.SeekStart)
+	require.Nil(t, err)
+	_, err = file.Write([]byte("WORLD"))
+	require.Nil(t, err)
+	_, err = file.WriteAt([]byte("H"), 0)
+	require.Nil(t, err)
+	_, err = file.Seek(0, io.SeekStart)
+	require.Nil(t, err)
+	data, err := io.ReadAll(file)
+	require.Nil(t, err)
+	require.Equal(t, "Hello, WORLD\n", string(data))
+	buffer := make([]byte, 5)
+	count, err = file.ReadAt(buffer, 7)
+	require.Nil(t, err)
+	require.Equal(t, "WORLD", string(buffer[:count]))
+	require.Nil(t, file.Truncate(5))
+	info, err := file.Stat()
+	require.Nil(t, err)
+	require.Equal(t, int64(5), info.Size())
+	require.Nil(t, file.Close())
+	require.ErrorIs(t, file.Close(), os.ErrClosed)
+
+	file, err = fsys.OpenFile(name, os.O_WRONLY|os.O_APPEND, 0)
+	require.Nil(t, err)
+	_, err = file.Write([]byte(" there"))
+	require.Nil(t, err)
+	_, err = file.Read(buffer)
+	require.NotNil(t, err)
+	require.Nil(t, file.Close())
+	data, err = afero.ReadFile(fsys, name)
+	require.Nil(t, err)
+	require.Equal(t, "Hello there", string(data))
+
+	_, err = fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	require.True(t, os.IsExist(err))
+	_, err = fsys.Open(filepath.Join(root, "missing.txt"))
+	require.True(t, os.IsNotExist(err))
+
+	require.Nil(t, fsys.Chmod(name, 0640))
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.Nil(t, fsys.Chtimes(name, mtime, mtime))
+	info, err = fsys.Stat(name)
+	require.Nil(t, err)
+	require.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	require.True(t, mtime.Equal(info.ModTime()))
+
+	dir := filepath.Join(root, "dir")
+	require.Nil(t, fsys.Mkdir(dir, 0755))
+	require.True(t, os.IsExist(fsys.Mkdir(dir, 0755)))
+	require.True(t, os.IsNotExist(fsys.Mkdir(filepath.Join(root, "x", "y"), 0755)))
+	require.Nil(t, fsys.MkdirAll(filepath.Join(dir, "sub", "sub"), 0755))
+	require.Nil(t, fsys.MkdirAll(filepath.Join(dir, "sub"), 0755))
+	require.Nil(t, fsys.Rename(name, filepath.Join(dir, "moved.txt")))
+	require.Nil(t, afero.WriteFile(fsys, filepath.Join(dir, "other.txt"), []byte("other"), 0600))
+
+	file, err = fsys.Open(dir)
+	require.Nil(t, err)
+	names, err := file.Readdirnames(2)
+	require.Nil(t, err)
+	require.Len(t, names, 2)
+	more, err := file.Readdirnames(2)
+	require.Nil(t, err)
+	require.Len(t, more, 1)
+	// the local disk returns entries in directory order
+	names = append(names, more...)
+	slices.Sort(names)
+	require.Equal(t, []string{"moved.txt", "other.txt", "sub"}, names)
+	_, err = file.Readdirnames(2)
+	require.Equal(t, io.EOF, err)
+	require.Nil(t, file.Close())
+
+	require.NotNil(t, fsys.Remove(dir))
+	require.Nil(t, fsys.Remove(filepath.Join(dir, "other.txt")))
+	require.True(t, os.IsNotExist(fsys.Remove(filepath.Join(dir, "other.txt"))))
+	require.Nil(t, fsys.RemoveAll(dir))
+	require.Nil(t, fsys.RemoveAll(dir))
+	exists, err := afero.Exists(fsys, dir)
+	require.Nil(t, err)
+	require.False(t, exists)
+}
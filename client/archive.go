@@ -0,0 +1,79 @@
+package client
+
+import (
+	"github.com/rstms/winexec/archive"
+	"github.com/rstms/winexec/message"
+	"io"
+	"log"
+)
+
+// directory trees are transferred as gzipped tar streams
+const TREE_ARCHIVE_FORMAT = archive.TGZ
+
+// copy the local directory src into the remote directory dst, which is created if
+// necessary; existing remote files are replaced only if force is set
+func (c *WinexecClient) UploadTree(dst, src string, force bool) error {
+	if c.debug {
+		log.Printf("winexec UploadTree(%s %s)\n", dst, src)
+	}
+	reader, writer := io.Pipe()
+	packed := make(chan error, 1)
+	go func() {
+		_, err := archive.Pack(writer, src, TREE_ARCHIVE_FORMAT)
+		writer.CloseWithError(err)
+		packed <- err
+	}()
+	request := message.ArchiveExtractRequest{
+		Pathname: dst,
+		Format:   TREE_ARCHIVE_FORMAT,
+		Force:    force,
+	}
+	if c.debug {
+		log.Printf("winexec archive extract request: %s\n", message.Redacted(request))
+	}
+	var response message.ArchiveResponse
+	err := c.post("/archive/extract/", &request, reader, &response)
+	reader.Close()
+	packErr := <-packed
+	if packErr != nil {
+		return Fatal(packErr)
+	}
+	if err != nil {
+		return Fatal(err)
+	}
+	if c.debug {
+		log.Printf("winexec archive extract response: %s\n", message.Redacted(response))
+	}
+	if !response.Success {
+		return Fatalf("WinExec: UploadTree failed: %v", response)
+	}
+	return nil
+}
+
+// copy the remote directory src into the local directory dst, which is created if
+// necessary; existing local files are replaced only if force is set
+func (c *WinexecClient) DownloadTree(dst, src string, force bool) error {
+	if c.debug {
+		log.Printf("winexec DownloadTree(%s %s)\n", dst, src)
+	}
+	request := message.ArchivePackRequest{
+		Pathname: src,
+		Format:   TREE_ARCHIVE_FORMAT,
+	}
+	if c.debug {
+		log.Printf("winexec archive pack request: %s\n", message.Redacted(request))
+	}
+	stream, err := c.stream("/archive/pack/", &request, nil)
+	if err != nil {
+		return Fatal(err)
+	}
+	defer stream.Body.Close()
+	stats, err := archive.Extract(stream.Body, dst, TREE_ARCHIVE_FORMAT, archive.Options{Force: force})
+	if err != nil {
+		return Fatal(err)
+	}
+	if c.debug {
+		log.Printf("winexec archive extracted: %s\n", message.Redacted(*stats))
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package client
+
+import (
+	"github.com/rstms/winexec/message"
+	"log"
+)
+
+// return the most recent server audit entries matching the query, oldest first
+func (c *WinexecClient) Audit(query *message.AuditQueryRequest) ([]message.AuditEntry, error) {
+	if c.debug {
+		log.Printf("winexec Audit(%s)\n", message.Redacted(query))
+	}
+	var response message.AuditQueryResponse
+	_, err := c.api.Post("/audit/", query, &response, nil)
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	if c.debug {
+		log.Printf("winexec audit response: %s\n", response.Message)
+	}
+	if !response.Success {
+		return nil, Fatalf("WinExec: audit failed: %v", response)
+	}
+	return response.Entries, nil
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"github.com/rstms/winexec/message"
+	"log"
+)
+
+// evaluate a command against the server's command rules without running it; a denied
+// command is not an error, and the response reports whether it would be allowed
+func (c *WinexecClient) CheckCommand(command string, args, env []string, options *message.ProcessOptions) (*message.CommandCheckResponse, error) {
+	if c.debug {
+		log.Printf("winexec CheckCommand(%s %v)\n", command, args)
+	}
+	request := message.CommandCheckRequest{Command: command, Args: args, Env: env}
+	if options != nil {
+		request.ProcessOptions = *options
+	}
+	if c.debug {
+		log.Printf("winexec check request: %s\n", message.Redacted(request))
+	}
+	var response message.CommandCheckResponse
+	_, err := c.api.Post("/exec/check/", &request, &response, nil)
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	if c.debug {
+		log.Printf("winexec check response: %s\n", message.Redacted(response))
+	}
+	if !response.Success {
+		return nil, Fatalf("WinExec: check failed: %v", response)
+	}
+	return &response, nil
+}
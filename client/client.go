@@ -2,28 +2,41 @@ package client
 
 import (
 	"fmt"
+	"github.com/rstms/winexec/checksum"
 	"github.com/rstms/winexec/message"
 	"github.com/rstms/winexec/server"
 	"github.com/spf13/viper"
+	"io"
 	"io/fs"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const Version = "1.2.13"
 
 const DEFAULT_AUTO_DELETE_SECONDS = 300
+const DEFAULT_RETRIES = 5
+const DEFAULT_RETRY_DELAY_SECONDS = 2
+const DEFAULT_UPLOAD_CHUNK_SIZE = 16 * 1024 * 1024
 
 type WinexecClient struct {
 	url               string
 	debug             bool
 	AutoDeleteSeconds int
+	Retries           int
+	RetryDelaySeconds int
+	UploadChunkSize   int64
 	certSubject       string
 	certDuration      string
 	api               APIClient
+	http              *http.Client
 	server            *server.WinexecServer
 }
 
@@ -53,6 +66,9 @@ func NewWinexecClient(caFile, certFile, keyFile string) (*WinexecClient, error)
 	ViperSetDefault(prefix+"hostname", defaultURL.Hostname())
 	ViperSetDefault(prefix+"https_port", defaultURL.Port())
 	ViperSetDefault(prefix+"path", defaultURL.Path)
+	ViperSetDefault(prefix+"retries", DEFAULT_RETRIES)
+	ViperSetDefault(prefix+"retry_delay_seconds", DEFAULT_RETRY_DELAY_SECONDS)
+	ViperSetDefault(prefix+"upload_chunk_size", DEFAULT_UPLOAD_CHUNK_SIZE)
 
 	winexecURL := url.URL{
 		Scheme: ViperGetString(prefix + "scheme"),
@@ -68,6 +84,9 @@ func NewWinexecClient(caFile, certFile, keyFile string) (*WinexecClient, error)
 		url:               winexecURL.String(),
 		debug:             ViperGetBool(prefix + "debug"),
 		AutoDeleteSeconds: ViperGetInt(prefix + "auto_delete_seconds"),
+		Retries:           ViperGetInt(prefix + "retries"),
+		RetryDelaySeconds: ViperGetInt(prefix + "retry_delay_seconds"),
+		UploadChunkSize:   ViperGetInt64(prefix + "upload_chunk_size"),
 	}
 
 	client.api, err = NewAPIClient("winexec", client.url, certFile, keyFile, caFile, nil)
@@ -75,21 +94,32 @@ func NewWinexecClient(caFile, certFile, keyFile string) (*WinexecClient, error)
 		return nil, Fatal(err)
 	}
 
+	client.http, err = newHTTPClient(caFile, certFile, keyFile)
+	if err != nil {
+		return nil, Fatal(err)
+	}
+
 	if client.debug {
 		log.Printf("NewWinexecClient: %+v\n", client)
 	}
 
+	// the embedded server runs until Close
 	if ViperGetBool(prefix + "enable_winexec_server") {
 		client.server, err = server.NewWinexecServer()
 		if err != nil {
 			return nil, Fatal(err)
 		}
+		err = client.server.Start()
+		if err != nil {
+			return nil, Fatal(err)
+		}
 	}
 
 	return &client, nil
 }
 
 func (c *WinexecClient) Close() error {
+	c.http.CloseIdleConnections()
 	if c.server != nil {
 		err := c.server.Stop()
 		if err != nil {
@@ -110,53 +140,75 @@ func (c *WinexecClient) GetConfig() map[string]any {
 	return cfg
 }
 
-func (c *WinexecClient) Spawn(command string, args, env []string, exitCode *int) error {
+// Spawn starts the process without waiting for it, so there is no exit code to return;
+// use SpawnJob and WaitJob to follow it
+func (c *WinexecClient) Spawn(command string, args, env []string, options *message.ProcessOptions) error {
+	_, err := c.SpawnJob(command, args, env, options)
+	return err
+}
+
+// SpawnJob returns the server's response, with the job ID and PID of the spawned process
+func (c *WinexecClient) SpawnJob(command string, args, env []string, options *message.ProcessOptions) (*message.SpawnResponse, error) {
 	if c.debug {
 		log.Printf("winexec Spawn(%s)\n", command)
 	}
 	request := message.SpawnRequest{Command: command, Args: args, Env: env}
+	if options != nil {
+		request.ProcessOptions = *options
+	}
 	var response message.SpawnResponse
 	if c.debug {
-		log.Printf("winexec spawn request: %+v\n", request)
+		log.Printf("winexec spawn request: %s\n", message.Redacted(request))
 	}
 
 	_, err := c.api.Post("/spawn/", &request, &response, nil)
 	if err != nil {
-		return Fatal(err)
+		return nil, Fatal(err)
 	}
 	if c.debug {
-		log.Printf("winexec spawn response: %+v\n", response)
+		log.Printf("winexec spawn response: %s\n", message.Redacted(response))
 	}
 	if !response.Success {
-		return Fatalf("WinExec: spawn failed: %v", response)
-	}
-	if exitCode != nil {
-		*exitCode = response.ExitCode
-	} else if response.ExitCode != 0 {
-		return Fatalf("Spawning process '%s' exited %d", command, response.ExitCode)
+		return nil, Fatalf("WinExec: spawn failed: %v", response)
 	}
-	return nil
+	return &response, nil
 }
 
-func (c *WinexecClient) Exec(command string, args, env []string, exitCode *int) (string, string, error) {
+func (c *WinexecClient) Exec(command string, args, env []string, stdin io.Reader, options *message.ProcessOptions, exitCode *int) (string, string, error) {
 	if c.debug {
 		log.Printf("winexec Exec(%s %v)\n", command, args)
 	}
 	request := message.ExecRequest{Command: command, Args: args, Env: env}
+	if options != nil {
+		request.ProcessOptions = *options
+	}
+	if stdin != nil {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", "", Fatal(err)
+		}
+		request.Stdin = data
+	}
 	var response message.ExecResponse
 	if c.debug {
-		log.Printf("winexec exec request: %+v\n", request)
+		log.Printf("winexec exec request: %s\n", message.Redacted(request))
 	}
 	_, err := c.api.Post("/exec/", &request, &response, nil)
 	if err != nil {
 		return "", "", Fatal(err)
 	}
 	if c.debug {
-		log.Printf("winexec exec response: %+v\n", response)
+		log.Printf("winexec exec response: %s\n", message.Redacted(response))
 	}
 	if !response.Success {
 		return "", "", Fatalf("WinExec: exec failed: %v", response)
 	}
+	if response.TimedOut {
+		if exitCode != nil {
+			*exitCode = response.ExitCode
+		}
+		return response.Stdout, response.Stderr, fmt.Errorf("Process '%s' %w after %d seconds", command, ErrTimedOut, request.TimeoutSeconds)
+	}
 	if exitCode != nil {
 		*exitCode = response.ExitCode
 	} else if response.ExitCode != 0 {
@@ -165,66 +217,209 @@ func (c *WinexecClient) Exec(command string, args, env []string, exitCode *int)
 	return response.Stdout, response.Stderr, nil
 }
 
+// the file is sent in chunks to an upload session on the server; after a
+// transient failure the upload resumes from the last offset the server confirmed;
+// the server verifies the SHA256 digest of the file before committing it
 func (c *WinexecClient) Upload(dst, src string, force bool) error {
 	if c.debug {
 		log.Printf("winexec Upload(%s %s)\n", dst, src)
 	}
 
-	data, err := os.ReadFile(src)
+	file, err := os.Open(src)
 	if err != nil {
 		return Fatal(err)
 	}
+	defer file.Close()
 
-	fileinfo, err := os.Stat(src)
+	fileinfo, err := file.Stat()
+	if err != nil {
+		return Fatal(err)
+	}
+	size := fileinfo.Size()
+	hasher, err := checksum.File(src, checksum.SHA256)
 	if err != nil {
 		return Fatal(err)
 	}
-	request := message.FileUploadRequest{
+	request := message.UploadSessionRequest{
 		Pathname:  dst,
-		Content:   data,
+		Size:      size,
 		Timestamp: fileinfo.ModTime(),
 		Mode:      fileinfo.Mode(),
 		Force:     force,
+		SHA256:    hasher.Sum(checksum.SHA256),
 	}
-	var response message.FileDownloadResponse
 	if c.debug {
-		log.Printf("winexec upload request: %+v\n", request)
+		log.Printf("winexec upload session request: %s\n", message.Redacted(request))
+	}
+	// the session is not created by a retried request, since a lost response would leave
+	// the session the server created behind
+	var session message.UploadSessionResponse
+	err = c.post("/upload/session/", &request, nil, &session)
+	if err != nil {
+		return Fatal(err)
+	}
+	if c.debug {
+		log.Printf("winexec upload session response: %s\n", message.Redacted(session))
+	}
+
+	err = c.uploadChunks(file, &session)
+	if err != nil {
+		abortErr := c.post("/upload/abort/", &message.UploadRequest{SessionID: session.SessionID}, nil, &session)
+		if abortErr != nil {
+			log.Printf("winexec upload abort failed: %v\n", abortErr)
+		}
+		return Fatal(err)
 	}
-	_, err = c.api.Post("/upload/", &request, &response, nil)
+
+	var response message.UploadSessionResponse
+	err = c.retry("upload commit", func() error {
+		return c.post("/upload/commit/", &message.UploadRequest{SessionID: session.SessionID}, nil, &response)
+	})
 	if err != nil {
 		return Fatal(err)
 	}
 	if c.debug {
-		log.Printf("winexec upload response: %+v\n", response)
+		log.Printf("winexec upload response: %s\n", message.Redacted(response))
 	}
 	if !response.Success {
 		return Fatalf("WinExec: Upload failed: %v", response)
 	}
+	err = hasher.Verify(checksum.SHA256, response.SHA256)
+	if err != nil {
+		return Fatal(err)
+	}
 	return nil
 }
 
+func (c *WinexecClient) uploadChunks(file *os.File, session *message.UploadSessionResponse) error {
+	offset := session.Offset
+	for offset < session.Size {
+		length := min(c.UploadChunkSize, session.Size-offset)
+		err := c.retry("upload chunk", func() error {
+			request := message.UploadRequest{SessionID: session.SessionID, Offset: offset}
+			var response message.UploadSessionResponse
+			err := c.post("/upload/chunk/", &request, io.NewSectionReader(file, offset, length), &response)
+			if err != nil {
+				if isTransient(err) {
+					// skip anything the server received before the failure
+					var status message.UploadSessionResponse
+					statusErr := c.post("/upload/status/", &message.UploadRequest{SessionID: session.SessionID}, nil, &status)
+					if statusErr == nil && status.Offset > offset {
+						offset = status.Offset
+					}
+				}
+				return err
+			}
+			if c.debug {
+				log.Printf("winexec upload chunk response: %s\n", message.Redacted(response))
+			}
+			offset = response.Offset
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the file is downloaded to a temporary file which replaces dst when complete;
+// after a transient failure the download resumes from the bytes received; each
+// segment received is verified against the digest the server sends after it
 func (c *WinexecClient) Download(dst, src string) error {
 	if c.debug {
 		log.Printf("winexec Download(%s %s)\n", dst, src)
 	}
-	request := message.FileDownloadRequest{
-		Pathname: src,
+
+	dir, name := filepath.Split(dst)
+	if dir == "" {
+		dir = "."
 	}
-	if c.debug {
-		log.Printf("winexec download request: %+v\n", request)
+	file, err := os.CreateTemp(dir, "."+name+".winexec-*")
+	if err != nil {
+		return Fatal(err)
 	}
-	var response message.FileDownloadResponse
-	_, err := c.api.Post("/download/", &request, &response, nil)
+	tempPathname := file.Name()
+	defer os.Remove(tempPathname)
+	defer file.Close()
+
+	var written int64
+	size := int64(-1)
+	var timestamp time.Time
+	err = c.retry("download", func() error {
+		request := message.FileDownloadRequest{
+			Pathname: src,
+			Offset:   written,
+		}
+		if c.debug {
+			log.Printf("winexec download request: %s\n", message.Redacted(request))
+		}
+		stream, err := c.stream("/download/stream/", &request, nil)
+		if err != nil {
+			return err
+		}
+		defer stream.Body.Close()
+		streamSize, err := strconv.ParseInt(stream.Header.Get(message.HEADER_SIZE), 10, 64)
+		if err != nil {
+			return &StatusError{Status: "invalid response", Message: err.Error()}
+		}
+		streamTimestamp, err := time.Parse(time.RFC3339Nano, stream.Header.Get(message.HEADER_TIMESTAMP))
+		if err != nil {
+			return &StatusError{Status: "invalid response", Message: err.Error()}
+		}
+		if c.debug {
+			log.Printf("winexec download response: pathname=%s size=%d timestamp=%v\n", stream.Header.Get(message.HEADER_PATHNAME), streamSize, streamTimestamp)
+		}
+		if size < 0 {
+			size = streamSize
+			timestamp = streamTimestamp
+		} else if size != streamSize || !timestamp.Equal(streamTimestamp) {
+			return &StatusError{Status: "resume failed", Message: "source file changed during download"}
+		}
+		hasher, err := checksum.New(checksum.SHA256)
+		if err != nil {
+			return err
+		}
+		count, err := io.Copy(io.MultiWriter(file, hasher), stream.Body)
+		// the server always sends the digest, so a missing trailer fails the download
+		// rather than skipping verification; a partial or corrupt segment is discarded
+		// so the retry fetches it again, keeping only verified bytes
+		if err == nil {
+			digest := stream.Trailer.Get(message.HEADER_SHA256)
+			if digest == "" {
+				err = &StatusError{Status: "invalid response", Message: "missing " + message.HEADER_SHA256 + " trailer"}
+			} else {
+				err = hasher.Verify(checksum.SHA256, digest)
+			}
+		}
+		if err != nil {
+			truncErr := file.Truncate(written)
+			if truncErr == nil {
+				_, truncErr = file.Seek(written, io.SeekStart)
+			}
+			if truncErr != nil {
+				return &StatusError{Status: "download failed", Message: truncErr.Error()}
+			}
+			return err
+		}
+		written += count
+		return nil
+	})
 	if err != nil {
 		return Fatal(err)
 	}
-	if c.debug {
-		log.Printf("winexec download response: %+v\n", response)
+	err = file.Close()
+	if err != nil {
+		return Fatal(err)
 	}
-	if !response.Success {
-		return Fatalf("WinExec: Download failed: %v", response)
+	if written != size {
+		return Fatalf("WinExec: Download size mismatch: expected %d, received %d", size, written)
+	}
+	err = os.Chtimes(tempPathname, time.Time{}, timestamp)
+	if err != nil {
+		return Fatal(err)
 	}
-	err = os.WriteFile(dst, response.Content, 0600)
+	err = os.Rename(tempPathname, dst)
 	if err != nil {
 		return Fatal(err)
 	}
@@ -232,13 +427,18 @@ func (c *WinexecClient) Download(dst, src string) error {
 }
 
 func (c *WinexecClient) GetISO(dst, url, ca, cert, key string, autoDeleteSeconds *int) error {
+	return c.GetISOVerified(dst, url, ca, cert, key, "", autoDeleteSeconds)
+}
+
+// the server downloads url to dst, keeping it only if its SHA256 digest matches sha256
+func (c *WinexecClient) GetISOVerified(dst, url, ca, cert, key, sha256 string, autoDeleteSeconds *int) error {
 	var seconds int
 	seconds = c.AutoDeleteSeconds
 	if autoDeleteSeconds != nil {
 		seconds = *autoDeleteSeconds
 	}
 	if c.debug {
-		log.Printf("winexec GetISO(dst=%s, url=%s, ca=%s, cert=%s, key=%s, seconds=%d)\n", dst, url, ca, cert, key, seconds)
+		log.Printf("winexec GetISO(dst=%s, url=%s, ca=%s, cert=%s, key=%s, sha256=%s, seconds=%d)\n", dst, message.RedactURL(url), ca, cert, key, sha256, seconds)
 	}
 	var err error
 	var caData []byte
@@ -269,10 +469,11 @@ func (c *WinexecClient) GetISO(dst, url, ca, cert, key string, autoDeleteSeconds
 		Cert:              certData,
 		Key:               keyData,
 		AutoDeleteSeconds: seconds,
+		SHA256:            sha256,
 	}
 
 	if c.debug {
-		log.Printf("winexec get request: %+v\n", request)
+		log.Printf("winexec get request: %s\n", message.Redacted(request))
 	}
 	var response message.FileGetResponse
 	_, err = c.api.Post("/get/", &request, &response, nil)
@@ -280,11 +481,14 @@ func (c *WinexecClient) GetISO(dst, url, ca, cert, key string, autoDeleteSeconds
 		return Fatal(err)
 	}
 	if c.debug {
-		log.Printf("winexec get response: %+v\n", response)
+		log.Printf("winexec get response: %s\n", message.Redacted(response))
 	}
 	if !response.Success {
 		return Fatalf("WinExec: GetISO failed: %v", response)
 	}
+	if sha256 != "" && !strings.EqualFold(sha256, response.SHA256) {
+		return Fatalf("WinExec: GetISO checksum mismatch: expected %s, received %s", sha256, response.SHA256)
+	}
 	return nil
 }
 
@@ -333,7 +537,7 @@ func (c *WinexecClient) DirEntries(pathname string) (map[string]message.Director
 		Pathname: pathname,
 	}
 	if c.debug {
-		log.Printf("winexec directory request: %+v\n", request)
+		log.Printf("winexec directory request: %s\n", message.Redacted(request))
 	}
 	var response message.DirectoryResponse
 	_, err := c.api.Post("/dir/", &request, &response, nil)
@@ -341,7 +545,7 @@ func (c *WinexecClient) DirEntries(pathname string) (map[string]message.Director
 		return entries, Fatal(err)
 	}
 	if c.debug {
-		log.Printf("winexec directory response: %+v\n", response)
+		log.Printf("winexec directory response: %s\n", message.Redacted(response))
 	}
 	if !response.Success {
 		return entries, Fatalf("WinExec: directory operation failed: %v", response)
@@ -361,7 +565,7 @@ func (c *WinexecClient) MkdirAll(pathname string, mode fs.FileMode) error {
 		Mode:     mode,
 	}
 	if c.debug {
-		log.Printf("winexec directory request: %+v\n", request)
+		log.Printf("winexec directory request: %s\n", message.Redacted(request))
 	}
 	var response message.DirectoryResponse
 	_, err := c.api.Post("/mkdir/", &request, &response, nil)
@@ -369,7 +573,7 @@ func (c *WinexecClient) MkdirAll(pathname string, mode fs.FileMode) error {
 		return Fatal(err)
 	}
 	if c.debug {
-		log.Printf("winexec directory response: %+v\n", response)
+		log.Printf("winexec directory response: %s\n", message.Redacted(response))
 	}
 	if !response.Success {
 		return Fatalf("WinExec: directory operation failed: %v", response)
@@ -385,7 +589,7 @@ func (c *WinexecClient) RemoveAll(pathname string) error {
 		Pathname: pathname,
 	}
 	if c.debug {
-		log.Printf("winexec directory request: %+v\n", request)
+		log.Printf("winexec directory request: %s\n", message.Redacted(request))
 	}
 	var response message.DirectoryResponse
 	_, err := c.api.Post("/rmdir/", &request, &response, nil)
@@ -393,7 +597,7 @@ func (c *WinexecClient) RemoveAll(pathname string) error {
 		return Fatal(err)
 	}
 	if c.debug {
-		log.Printf("winexec directory response: %+v\n", response)
+		log.Printf("winexec directory response: %s\n", message.Redacted(response))
 	}
 	if !response.Success {
 		return Fatalf("WinExec: directory destroy failed: %v", response)
@@ -409,7 +613,7 @@ func (c *WinexecClient) IsFile(pathname string) (bool, error) {
 		Pathname: pathname,
 	}
 	if c.debug {
-		log.Printf("winexec isfile request: %+v\n", request)
+		log.Printf("winexec isfile request: %s\n", message.Redacted(request))
 	}
 	var response message.IsResponse
 	_, err := c.api.Post("/isfile/", &request, &response, nil)
@@ -417,7 +621,7 @@ func (c *WinexecClient) IsFile(pathname string) (bool, error) {
 		return false, Fatal(err)
 	}
 	if c.debug {
-		log.Printf("winexec isfile response: %+v\n", response)
+		log.Printf("winexec isfile response: %s\n", message.Redacted(response))
 	}
 	if !response.Success {
 		return false, Fatalf("WinExec: isfile failed: %v", response)
@@ -433,7 +637,7 @@ func (c *WinexecClient) IsDir(pathname string) (bool, error) {
 		Pathname: pathname,
 	}
 	if c.debug {
-		log.Printf("winexec isdir request: %+v\n", request)
+		log.Printf("winexec isdir request: %s\n", message.Redacted(request))
 	}
 	var response message.IsResponse
 	_, err := c.api.Post("/isdir/", &request, &response, nil)
@@ -441,7 +645,7 @@ func (c *WinexecClient) IsDir(pathname string) (bool, error) {
 		return false, Fatal(err)
 	}
 	if c.debug {
-		log.Printf("winexec isdir response: %+v\n", response)
+		log.Printf("winexec isdir response: %s\n", message.Redacted(response))
 	}
 	if !response.Success {
 		return false, Fatalf("WinExec: isdir failed: %v", response)
@@ -457,7 +661,7 @@ func (c *WinexecClient) DeleteFile(pathname string) error {
 		Pathname: pathname,
 	}
 	if c.debug {
-		log.Printf("winexec delete file request: %+v\n", request)
+		log.Printf("winexec delete file request: %s\n", message.Redacted(request))
 	}
 	var response message.FileResponse
 	_, err := c.api.Post("/delete/", &request, &response, nil)
@@ -465,7 +669,7 @@ func (c *WinexecClient) DeleteFile(pathname string) error {
 		return Fatal(err)
 	}
 	if c.debug {
-		log.Printf("winexec file response: %+v\n", response)
+		log.Printf("winexec file response: %s\n", message.Redacted(response))
 	}
 	if !response.Success {
 		return Fatalf("WinExec: DeleteFile failed: %v", response)
@@ -483,10 +687,28 @@ func (c *WinexecClient) GetOS() (string, error) {
 		return "", Fatal(err)
 	}
 	if c.debug {
-		log.Printf("winexec getos response: %+v\n", response)
+		log.Printf("winexec getos response: %s\n", message.Redacted(response))
 	}
 	if !response.Success {
 		return "", Fatalf("WinExec: getos failed: %v", response)
 	}
 	return response.OS, nil
 }
+
+func (c *WinexecClient) Ping() error {
+	if c.debug {
+		log.Println("winexec Ping()")
+	}
+	var response message.SuccessResponse
+	_, err := c.api.Get("/ping/", &response)
+	if err != nil {
+		return Fatal(err)
+	}
+	if c.debug {
+		log.Printf("winexec ping response: %s\n", message.Redacted(response))
+	}
+	if !response.Success {
+		return Fatalf("WinExec: ping failed: %v", response)
+	}
+	return nil
+}
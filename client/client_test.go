@@ -2,14 +2,24 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"github.com/rstms/winexec/checksum"
 	"github.com/rstms/winexec/message"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
+	"io"
 	"io/fs"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"slices"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -26,8 +36,12 @@ func dumpConfig(t *testing.T) {
 	log.Println(buf.String())
 }
 
+// these tests need a live windows host; the wintest harness covers the same
+// operations against a server running in this process
 func initTestConfig(t *testing.T) {
-	require.NotEmpty(t, os.Getenv("WINEXEC_HOST"))
+	if os.Getenv("WINEXEC_HOST") == "" {
+		t.Skip("WINEXEC_HOST not set")
+	}
 	testFile := filepath.Join("testdata", "config.yaml")
 	Init("test", Version, testFile)
 	ViperSet("debug", true)
@@ -171,3 +185,25 @@ func TestWinexecClientGetISODefaultDelete(t *testing.T) {
 	err := c.GetISO("/c/tmp/testfile_default.iso", testURL, "", "", "", nil)
 	require.Nil(t, err)
 }
+
+func TestIsTransient(t *testing.T) {
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+	for _, test := range []struct {
+		err       error
+		transient bool
+	}{
+		{&StatusError{StatusCode: http.StatusServiceUnavailable}, true},
+		{&StatusError{StatusCode: http.StatusNotFound}, false},
+		{&url.Error{Op: "Post", URL: "https://host", Err: reset}, true},
+		{fmt.Errorf("copy: %w", io.ErrUnexpectedEOF), true},
+		{&net.OpError{Op: "write", Net: "tcp", Err: os.ErrDeadlineExceeded}, true},
+		{fmt.Errorf("segment: %w", checksum.ErrMismatch), true},
+		{x509.UnknownAuthorityError{}, false},
+		{&json.SyntaxError{}, false},
+		{&url.Error{Op: "Post", URL: "https://host", Err: context.Canceled}, false},
+		{&url.Error{Op: "Post", URL: "https://host", Err: context.DeadlineExceeded}, false},
+		{&fs.PathError{Op: "open", Path: "file", Err: fs.ErrNotExist}, false},
+	} {
+		require.Equal(t, test.transient, isTransient(test.err), test.err)
+	}
+}
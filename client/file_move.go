@@ -0,0 +1,82 @@
+package client
+
+import (
+	"errors"
+	"github.com/rstms/winexec/message"
+	"io/fs"
+	"log"
+	"net/http"
+)
+
+// a missing source or existing destination is returned as an *fs.PathError wrapping
+// fs.ErrNotExist, fs.ErrExist or ErrPathRejected, not wrapped with Fatal so callers can use errors.Is
+func moveError(op, dst, src string, err error) error {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusNotFound:
+			return &fs.PathError{Op: op, Path: src, Err: fs.ErrNotExist}
+		case http.StatusConflict:
+			return &fs.PathError{Op: op, Path: dst, Err: fs.ErrExist}
+		case message.STATUS_PATH_REJECTED:
+			return &fs.PathError{Op: op, Path: src, Err: ErrPathRejected}
+		}
+	}
+	return Fatal(err)
+}
+
+// rename or move src to dst on the server; an existing dst file is replaced only if overwrite is set
+func (c *WinexecClient) Rename(dst, src string, overwrite bool) error {
+	if c.debug {
+		log.Printf("winexec Rename(%s %s)\n", dst, src)
+	}
+	request := message.FileRenameRequest{
+		Source:      src,
+		Destination: dst,
+		Overwrite:   overwrite,
+	}
+	if c.debug {
+		log.Printf("winexec rename request: %s\n", message.Redacted(request))
+	}
+	var response message.FileResponse
+	err := c.post("/rename/", &request, nil, &response)
+	if err != nil {
+		return moveError("rename", dst, src, err)
+	}
+	if c.debug {
+		log.Printf("winexec rename response: %s\n", message.Redacted(response))
+	}
+	if !response.Success {
+		return Fatalf("WinExec: Rename failed: %v", response)
+	}
+	return nil
+}
+
+// copy src to dst on the server, keeping modes and modification times; recursive
+// is required to copy a directory, and overwrite allows existing files to be replaced
+func (c *WinexecClient) Copy(dst, src string, overwrite, recursive bool) error {
+	if c.debug {
+		log.Printf("winexec Copy(%s %s)\n", dst, src)
+	}
+	request := message.FileCopyRequest{
+		Source:      src,
+		Destination: dst,
+		Overwrite:   overwrite,
+		Recursive:   recursive,
+	}
+	if c.debug {
+		log.Printf("winexec copy request: %s\n", message.Redacted(request))
+	}
+	var response message.FileResponse
+	err := c.post("/copy/", &request, nil, &response)
+	if err != nil {
+		return moveError("copy", dst, src, err)
+	}
+	if c.debug {
+		log.Printf("winexec copy response: %s\n", message.Redacted(response))
+	}
+	if !response.Success {
+		return Fatalf("WinExec: Copy failed: %v", response)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"errors"
+	"github.com/rstms/winexec/message"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"time"
+)
+
+// missing, existing and inaccessible paths are returned as an *fs.PathError, not
+// wrapped with Fatal so callers can use errors.Is
+func fileError(op, pathname string, err error) error {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusNotFound:
+			return &fs.PathError{Op: op, Path: pathname, Err: fs.ErrNotExist}
+		case http.StatusConflict:
+			return &fs.PathError{Op: op, Path: pathname, Err: fs.ErrExist}
+		case http.StatusForbidden:
+			return &fs.PathError{Op: op, Path: pathname, Err: fs.ErrPermission}
+		case message.STATUS_PATH_REJECTED:
+			return &fs.PathError{Op: op, Path: pathname, Err: ErrPathRejected}
+		}
+	}
+	return Fatal(err)
+}
+
+// post a request for a single file operation that returns a FileResponse
+func (c *WinexecClient) fileOp(op, path, pathname string, request any, body io.Reader) (*message.FileResponse, error) {
+	if c.debug {
+		log.Printf("winexec %s request: %s\n", op, message.Redacted(request))
+	}
+	var response message.FileResponse
+	err := c.post(path, request, body, &response)
+	if err != nil {
+		return nil, fileError(op, pathname, err)
+	}
+	if c.debug {
+		log.Printf("winexec %s response: %s\n", op, message.Redacted(response))
+	}
+	if !response.Success {
+		return nil, Fatalf("WinExec: %s failed: %v", op, response)
+	}
+	return &response, nil
+}
+
+// open or create a remote file as os.OpenFile does, returning its information
+func (c *WinexecClient) openFile(request *message.FileOpenRequest) (*message.FileStat, error) {
+	if c.debug {
+		log.Printf("winexec open request: %s\n", message.Redacted(request))
+	}
+	var response message.StatResponse
+	err := c.post("/open/", request, nil, &response)
+	if err != nil {
+		return nil, fileError("open", request.Pathname, err)
+	}
+	if c.debug {
+		log.Printf("winexec open response: %s\n", message.Redacted(response))
+	}
+	if !response.Success {
+		return nil, Fatalf("WinExec: open failed: %v", response)
+	}
+	return &response.Stat, nil
+}
+
+// write data to an existing remote file at offset, returning the count of bytes written
+func (c *WinexecClient) WriteAt(pathname string, offset int64, data io.Reader) (int64, error) {
+	if c.debug {
+		log.Printf("winexec WriteAt(%s, %d)\n", pathname, offset)
+	}
+	request := message.FileWriteRequest{
+		Pathname: pathname,
+		Offset:   offset,
+	}
+	response, err := c.fileOp("write", "/write/", pathname, &request, data)
+	if err != nil {
+		return 0, err
+	}
+	return response.Bytes, nil
+}
+
+func (c *WinexecClient) Truncate(pathname string, size int64) error {
+	if c.debug {
+		log.Printf("winexec Truncate(%s, %d)\n", pathname, size)
+	}
+	request := message.FileTruncateRequest{
+		Pathname: pathname,
+		Size:     size,
+	}
+	_, err := c.fileOp("truncate", "/truncate/", pathname, &request, nil)
+	return err
+}
+
+func (c *WinexecClient) Chmod(pathname string, mode fs.FileMode) error {
+	if c.debug {
+		log.Printf("winexec Chmod(%s, %v)\n", pathname, mode)
+	}
+	request := message.FileChmodRequest{
+		Pathname: pathname,
+		Mode:     mode,
+	}
+	_, err := c.fileOp("chmod", "/chmod/", pathname, &request, nil)
+	return err
+}
+
+// ownership can only be changed on unix servers
+func (c *WinexecClient) Chown(pathname string, uid, gid int) error {
+	if c.debug {
+		log.Printf("winexec Chown(%s, %d, %d)\n", pathname, uid, gid)
+	}
+	request := message.FileChownRequest{
+		Pathname: pathname,
+		UID:      uid,
+		GID:      gid,
+	}
+	_, err := c.fileOp("chown", "/chown/", pathname, &request, nil)
+	return err
+}
+
+func (c *WinexecClient) Chtimes(pathname string, atime, mtime time.Time) error {
+	if c.debug {
+		log.Printf("winexec Chtimes(%s, %v, %v)\n", pathname, atime, mtime)
+	}
+	request := message.FileChtimesRequest{
+		Pathname:   pathname,
+		AccessTime: atime,
+		ModTime:    mtime,
+	}
+	_, err := c.fileOp("chtimes", "/chtimes/", pathname, &request, nil)
+	return err
+}
+
+// remove a file or empty directory; unlike DeleteFile, a missing file is an error
+func (c *WinexecClient) Remove(pathname string) error {
+	if c.debug {
+		log.Printf("winexec Remove(%s)\n", pathname)
+	}
+	return c.remove(pathname, false)
+}
+
+func (c *WinexecClient) remove(pathname string, recursive bool) error {
+	request := message.FileRemoveRequest{
+		Pathname:  pathname,
+		Recursive: recursive,
+	}
+	_, err := c.fileOp("remove", "/remove/", pathname, &request, nil)
+	return err
+}
+
+// create a single directory; the parent must exist and the directory must not
+func (c *WinexecClient) Mkdir(pathname string, mode fs.FileMode) error {
+	if c.debug {
+		log.Printf("winexec Mkdir(%s, %v)\n", pathname, mode)
+	}
+	request := message.DirectoryCreateRequest{
+		Pathname:  pathname,
+		Mode:      mode,
+		NoParents: true,
+	}
+	if c.debug {
+		log.Printf("winexec directory request: %s\n", message.Redacted(request))
+	}
+	var response message.DirectoryResponse
+	err := c.post("/mkdir/", &request, nil, &response)
+	if err != nil {
+		return fileError("mkdir", pathname, err)
+	}
+	if c.debug {
+		log.Printf("winexec directory response: %s\n", message.Redacted(response))
+	}
+	if !response.Success {
+		return Fatalf("WinExec: directory operation failed: %v", response)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"github.com/rstms/winexec/message"
+	"io/fs"
+	"time"
+)
+
+// fileInfo adapts a FileStat to fs.FileInfo and fs.DirEntry; Sys returns the *message.FileStat
+type fileInfo struct {
+	stat message.FileStat
+}
+
+func (f *fileInfo) Name() string {
+	return f.stat.Name
+}
+
+func (f *fileInfo) Size() int64 {
+	return f.stat.Size
+}
+
+func (f *fileInfo) Mode() fs.FileMode {
+	return f.stat.Mode
+}
+
+func (f *fileInfo) ModTime() time.Time {
+	return f.stat.ModTime
+}
+
+func (f *fileInfo) IsDir() bool {
+	return f.stat.Mode.IsDir()
+}
+
+func (f *fileInfo) Sys() any {
+	return &f.stat
+}
+
+func (f *fileInfo) Type() fs.FileMode {
+	return f.stat.Mode.Type()
+}
+
+func (f *fileInfo) Info() (fs.FileInfo, error) {
+	return f, nil
+}
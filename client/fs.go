@@ -0,0 +1,305 @@
+package client
+
+import (
+	"errors"
+	"github.com/rstms/winexec/message"
+	"github.com/rstms/winexec/ospath"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// FS presents a remote directory as an fs.FS, fs.ReadDirFS, fs.StatFS and fs.ReadFileFS;
+// names are slash separated and relative to the root, and files opened for reading
+// implement io.Seeker and io.ReaderAt using ranged downloads
+type FS struct {
+	client *WinexecClient
+	root   string
+}
+
+// the root may be given in any form accepted by ospath
+func (c *WinexecClient) FS(root string) *FS {
+	return &FS{client: c, root: ospath.UnixPath(root)}
+}
+
+func (f *FS) pathname(name string) string {
+	return path.Join(f.root, name)
+}
+
+// backslashes are rejected since ospath would map them to separators
+func validPath(name string) bool {
+	return fs.ValidPath(name) && !strings.Contains(name, `\`)
+}
+
+// return an error referring to the fs name rather than the remote pathname
+func (f *FS) pathError(op, name string, err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return &fs.PathError{Op: op, Path: name, Err: pathErr.Err}
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (f *FS) Open(name string) (fs.File, error) {
+	info, err := f.Stat(name)
+	if err != nil {
+		return nil, f.pathError("open", name, err)
+	}
+	if info.IsDir() {
+		return &dirFile{fsys: f, name: name, info: info}, nil
+	}
+	return &remoteFile{client: f.client, pathname: f.pathname(name), name: name, info: info}, nil
+}
+
+// symlinks are followed, as fs.StatFS requires
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	if !validPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	stat, err := f.client.stat(f.pathname(name), true)
+	if err != nil {
+		return nil, f.pathError("stat", name, err)
+	}
+	return &fileInfo{stat: *stat}, nil
+}
+
+// entries are sorted by name
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !validPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, err := f.client.readDir(f.pathname(name))
+	if err != nil {
+		return nil, f.pathError("readdir", name, err)
+	}
+	return entries, nil
+}
+
+// list a remote directory's entries sorted by name
+func (c *WinexecClient) readDir(root string) ([]fs.DirEntry, error) {
+	entries := []fs.DirEntry{}
+	// the root is always walked first
+	first := true
+	err := c.Walk(root, &message.WalkOptions{MaxDepth: 1}, func(pathname string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if first {
+			first = false
+			if !entry.IsDir() {
+				return errors.New("not a directory")
+			}
+			return nil
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries, nil
+}
+
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	if !validPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	reader, err := f.client.openRange(f.pathname(name), 0, 0)
+	if err != nil {
+		return nil, f.pathError("readfile", name, err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, f.pathError("readfile", name, err)
+	}
+	return data, nil
+}
+
+// return a reader for length bytes of a remote file starting at offset; a zero length
+// reads to the end of the file, and an offset past the end returns io.EOF
+func (c *WinexecClient) openRange(pathname string, offset, length int64) (io.ReadCloser, error) {
+	request := message.FileDownloadRequest{
+		Pathname: pathname,
+		Offset:   offset,
+		Length:   length,
+	}
+	stream, err := c.stream("/download/stream/", &request, nil)
+	if err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) {
+			switch statusErr.StatusCode {
+			case http.StatusNotFound:
+				return nil, &fs.PathError{Op: "open", Path: pathname, Err: fs.ErrNotExist}
+			case http.StatusRequestedRangeNotSatisfiable:
+				return nil, io.EOF
+			case message.STATUS_PATH_REJECTED:
+				return nil, &fs.PathError{Op: "open", Path: pathname, Err: ErrPathRejected}
+			}
+		}
+		return nil, err
+	}
+	return stream.Body, nil
+}
+
+// a remote file is read sequentially from a ranged download opened at the current offset
+type remoteFile struct {
+	client   *WinexecClient
+	pathname string
+	name     string
+	info     fs.FileInfo
+	offset   int64
+	reader   io.ReadCloser
+	closed   bool
+}
+
+func (r *remoteFile) Stat() (fs.FileInfo, error) {
+	if r.closed {
+		return nil, &fs.PathError{Op: "stat", Path: r.name, Err: fs.ErrClosed}
+	}
+	return r.info, nil
+}
+
+func (r *remoteFile) Read(data []byte) (int, error) {
+	if r.closed {
+		return 0, &fs.PathError{Op: "read", Path: r.name, Err: fs.ErrClosed}
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	if r.reader == nil {
+		reader, err := r.client.openRange(r.pathname, r.offset, 0)
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, &fs.PathError{Op: "read", Path: r.name, Err: err}
+		}
+		r.reader = reader
+	}
+	count, err := r.reader.Read(data)
+	r.offset += int64(count)
+	if err == io.EOF {
+		r.reader.Close()
+		r.reader = nil
+	}
+	return count, err
+}
+
+func (r *remoteFile) ReadAt(data []byte, offset int64) (int, error) {
+	if r.closed {
+		return 0, &fs.PathError{Op: "read", Path: r.name, Err: fs.ErrClosed}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "read", Path: r.name, Err: fs.ErrInvalid}
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+	reader, err := r.client.openRange(r.pathname, offset, int64(len(data)))
+	if err == io.EOF {
+		return 0, io.EOF
+	}
+	if err != nil {
+		return 0, &fs.PathError{Op: "read", Path: r.name, Err: err}
+	}
+	defer reader.Close()
+	count, err := io.ReadFull(reader, data)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return count, err
+}
+
+func (r *remoteFile) Seek(offset int64, whence int) (int64, error) {
+	if r.closed {
+		return 0, &fs.PathError{Op: "seek", Path: r.name, Err: fs.ErrClosed}
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.info.Size()
+	}
+	if offset < 0 || whence < io.SeekStart || whence > io.SeekEnd {
+		return 0, &fs.PathError{Op: "seek", Path: r.name, Err: fs.ErrInvalid}
+	}
+	if offset != r.offset && r.reader != nil {
+		r.reader.Close()
+		r.reader = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+func (r *remoteFile) Close() error {
+	if r.closed {
+		return &fs.PathError{Op: "close", Path: r.name, Err: fs.ErrClosed}
+	}
+	r.closed = true
+	if r.reader != nil {
+		return r.reader.Close()
+	}
+	return nil
+}
+
+// a directory's entries are read from the server on the first ReadDir call
+type dirFile struct {
+	fsys    *FS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	loaded  bool
+	closed  bool
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) {
+	if d.closed {
+		return nil, &fs.PathError{Op: "stat", Path: d.name, Err: fs.ErrClosed}
+	}
+	return d.info, nil
+}
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *dirFile) ReadDir(count int) ([]fs.DirEntry, error) {
+	if d.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fs.ErrClosed}
+	}
+	if !d.loaded {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.loaded = true
+	}
+	if count <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	count = min(count, len(d.entries))
+	entries := d.entries[:count]
+	d.entries = d.entries[count:]
+	return entries, nil
+}
+
+func (d *dirFile) Close() error {
+	if d.closed {
+		return &fs.PathError{Op: "close", Path: d.name, Err: fs.ErrClosed}
+	}
+	d.closed = true
+	return nil
+}
@@ -0,0 +1,61 @@
+package client_test
+
+import (
+	"errors"
+	"github.com/rstms/winexec/wintest"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	h := wintest.New(t)
+	c := h.Client
+	root := h.Dir
+	require.Nil(t, os.MkdirAll(filepath.Join(root, "dir", "empty"), 0700))
+	require.Nil(t, os.WriteFile(filepath.Join(root, "hello.txt"), []byte("hello, world\n"), 0600))
+	require.Nil(t, os.WriteFile(filepath.Join(root, "dir", "data.bin"), make([]byte, 10000), 0600))
+	require.Nil(t, os.WriteFile(filepath.Join(root, "dir", "empty.txt"), []byte{}, 0600))
+
+	fsys := c.FS(root)
+	require.Nil(t, fstest.TestFS(fsys, "hello.txt", "dir/data.bin", "dir/empty.txt", "dir/empty"))
+
+	_, err := fsys.Open("missing.txt")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+	_, err = fs.ReadFile(fsys, "dir/missing.bin")
+	require.True(t, errors.Is(err, fs.ErrNotExist))
+	_, err = fsys.Open("../escape")
+	require.True(t, errors.Is(err, fs.ErrInvalid))
+
+	file, err := fsys.Open("hello.txt")
+	require.Nil(t, err)
+	defer file.Close()
+	seeker := file.(io.ReadSeeker)
+	_, err = seeker.Seek(7, io.SeekStart)
+	require.Nil(t, err)
+	data, err := io.ReadAll(seeker)
+	require.Nil(t, err)
+	require.Equal(t, "world\n", string(data))
+}
+
+func TestFSSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require privileges on windows")
+	}
+	h := wintest.New(t)
+	require.Nil(t, os.WriteFile(h.Path("target.txt"), []byte("target\n"), 0600))
+	require.Nil(t, os.Symlink(h.Path("target.txt"), h.Path("link.txt")))
+	fsys := h.Client.FS(h.Dir)
+	info, err := fs.Stat(fsys, "link.txt")
+	require.Nil(t, err)
+	require.True(t, info.Mode().IsRegular())
+	require.Equal(t, int64(len("target\n")), info.Size())
+	stat, err := h.Client.Stat(h.Path("link.txt"))
+	require.Nil(t, err)
+	require.NotZero(t, stat.Mode&fs.ModeSymlink)
+}
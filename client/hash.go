@@ -0,0 +1,34 @@
+package client
+
+import (
+	"github.com/rstms/winexec/message"
+	"log"
+)
+
+// return digests of a remote file, or of every file below a remote directory, keyed by
+// slash separated relative pathname; algorithms defaults to sha256, and checksum.Path
+// returns local digests keyed the same way for comparison
+func (c *WinexecClient) Hash(pathname string, algorithms ...string) (map[string]message.FileHash, error) {
+	if c.debug {
+		log.Printf("winexec Hash(%s, %v)\n", pathname, algorithms)
+	}
+	request := message.HashRequest{
+		Pathname:   pathname,
+		Algorithms: algorithms,
+	}
+	if c.debug {
+		log.Printf("winexec hash request: %s\n", message.Redacted(request))
+	}
+	var response message.HashResponse
+	_, err := c.api.Post("/hash/", &request, &response, nil)
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	if c.debug {
+		log.Printf("winexec hash response: %s\n", message.Redacted(response))
+	}
+	if !response.Success {
+		return nil, Fatalf("WinExec: hash failed: %v", response)
+	}
+	return response.Files, nil
+}
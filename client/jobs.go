@@ -0,0 +1,134 @@
+package client
+
+import (
+	"github.com/rstms/winexec/message"
+	"io"
+	"log"
+)
+
+func (c *WinexecClient) StartJob(command string, args, env []string, stdin io.Reader, options *message.ProcessOptions) (string, error) {
+	if c.debug {
+		log.Printf("winexec StartJob(%s %v)\n", command, args)
+	}
+	request := message.ExecRequest{Command: command, Args: args, Env: env}
+	if options != nil {
+		request.ProcessOptions = *options
+	}
+	if stdin != nil {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", Fatal(err)
+		}
+		request.Stdin = data
+	}
+	if c.debug {
+		log.Printf("winexec job start request: %s\n", message.Redacted(request))
+	}
+	var response message.JobResponse
+	_, err := c.api.Post("/job/start/", &request, &response, nil)
+	if err != nil {
+		return "", Fatal(err)
+	}
+	if c.debug {
+		log.Printf("winexec job start response: %s\n", message.Redacted(response))
+	}
+	if !response.Success {
+		return "", Fatalf("WinExec: job start failed: %v", response)
+	}
+	return response.Job.ID, nil
+}
+
+func (c *WinexecClient) jobRequest(path string, request *message.JobRequest) (*message.JobStatus, error) {
+	if c.debug {
+		log.Printf("winexec job request: %s %s\n", path, message.Redacted(*request))
+	}
+	var response message.JobResponse
+	_, err := c.api.Post(path, request, &response, nil)
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	if c.debug {
+		log.Printf("winexec job response: %s\n", message.Redacted(response))
+	}
+	if !response.Success {
+		return nil, Fatalf("WinExec: job request failed: %v", response)
+	}
+	return &response.Job, nil
+}
+
+func (c *WinexecClient) JobStatus(id string) (*message.JobStatus, error) {
+	if c.debug {
+		log.Printf("winexec JobStatus(%s)\n", id)
+	}
+	status, err := c.jobRequest("/job/status/", &message.JobRequest{ID: id})
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	return status, nil
+}
+
+// wait for a job to exit; if timeoutSeconds is nonzero and the job is still
+// running when it expires, the returned status has Running set
+func (c *WinexecClient) WaitJob(id string, timeoutSeconds int) (*message.JobStatus, error) {
+	if c.debug {
+		log.Printf("winexec WaitJob(%s, %d)\n", id, timeoutSeconds)
+	}
+	status, err := c.jobRequest("/job/wait/", &message.JobRequest{ID: id, TimeoutSeconds: timeoutSeconds})
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	return status, nil
+}
+
+func (c *WinexecClient) KillJob(id string) error {
+	if c.debug {
+		log.Printf("winexec KillJob(%s)\n", id)
+	}
+	_, err := c.jobRequest("/job/kill/", &message.JobRequest{ID: id})
+	if err != nil {
+		return Fatal(err)
+	}
+	return nil
+}
+
+func (c *WinexecClient) ListJobs() ([]message.JobStatus, error) {
+	if c.debug {
+		log.Println("winexec ListJobs()")
+	}
+	var response message.JobListResponse
+	_, err := c.api.Get("/jobs/", &response)
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	if c.debug {
+		log.Printf("winexec job list response: %s\n", message.Redacted(response))
+	}
+	if !response.Success {
+		return nil, Fatalf("WinExec: job list failed: %v", response)
+	}
+	return response.Jobs, nil
+}
+
+// return job output following the offsets; pass the returned offsets to read incrementally
+func (c *WinexecClient) JobOutput(id string, stdoutOffset, stderrOffset int64) (*message.JobOutputResponse, error) {
+	if c.debug {
+		log.Printf("winexec JobOutput(%s, %d, %d)\n", id, stdoutOffset, stderrOffset)
+	}
+	request := message.JobOutputRequest{
+		ID:           id,
+		StdoutOffset: stdoutOffset,
+		StderrOffset: stderrOffset,
+	}
+	var response message.JobOutputResponse
+	_, err := c.api.Post("/job/output/", &request, &response, nil)
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	if c.debug {
+		log.Printf("winexec job output response: %s\n", message.Redacted(response))
+	}
+	if !response.Success {
+		return nil, Fatalf("WinExec: job output failed: %v", response)
+	}
+	return &response, nil
+}
@@ -0,0 +1,581 @@
+package client_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/rstms/winexec/checksum"
+	"github.com/rstms/winexec/client"
+	"github.com/rstms/winexec/message"
+	"github.com/rstms/winexec/server"
+	"github.com/rstms/winexec/wintest"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// these tests cover every server endpoint through a server running in this process
+
+func TestLocalPingOS(t *testing.T) {
+	c := wintest.New(t).Client
+	require.Nil(t, c.Ping())
+	remoteOS, err := c.GetOS()
+	require.Nil(t, err)
+	require.Equal(t, runtime.GOOS, remoteOS)
+}
+
+func TestLocalTwoServers(t *testing.T) {
+	one := wintest.New(t)
+	two := wintest.New(t)
+	require.NotEqual(t, one.URL, two.URL)
+	require.Nil(t, one.Client.Ping())
+	require.Nil(t, two.Client.Ping())
+	_, err := one.Client.StartJob("true", nil, nil, nil, nil)
+	require.Nil(t, err)
+	jobs, err := two.Client.ListJobs()
+	require.Nil(t, err)
+	require.Empty(t, jobs)
+}
+
+func TestLocalExec(t *testing.T) {
+	h := wintest.New(t)
+	c := h.Client
+	var exitCode int
+	stdout, stderr, err := c.Exec("sh", []string{"-c", "cat; echo $GREETING >&2; pwd; exit 3"}, []string{"GREETING=howdy"}, strings.NewReader("piped\n"), &message.ProcessOptions{Dir: h.Dir}, &exitCode)
+	require.Nil(t, err)
+	require.Equal(t, "piped\n"+h.Dir+"\n", stdout)
+	require.Equal(t, "howdy\n", stderr)
+	require.Equal(t, 3, exitCode)
+	_, _, err = c.Exec("sh", []string{"-c", "exit 1"}, nil, nil, nil, nil)
+	require.NotNil(t, err)
+
+	var out, errOut bytes.Buffer
+	err = c.ExecStream("sh", []string{"-c", "cat; echo oops >&2; exit 4"}, nil, strings.NewReader("streamed\n"), &out, &errOut, nil, &exitCode)
+	require.Nil(t, err)
+	require.Equal(t, "streamed\n", out.String())
+	require.Equal(t, "oops\n", errOut.String())
+	require.Equal(t, 4, exitCode)
+
+	// the exit code of a process killed on timeout is set as for any exit
+	options := message.ProcessOptions{TimeoutSeconds: 1}
+	exitCode = 0
+	_, _, err = c.Exec("sh", []string{"-c", "sleep 30"}, nil, nil, &options, &exitCode)
+	require.ErrorIs(t, err, client.ErrTimedOut)
+	require.NotZero(t, exitCode)
+	exitCode = 0
+	err = c.ExecStream("sh", []string{"-c", "sleep 30"}, nil, nil, nil, nil, &options, &exitCode)
+	require.ErrorIs(t, err, client.ErrTimedOut)
+	require.NotZero(t, exitCode)
+}
+
+func TestLocalSpawn(t *testing.T) {
+	h := wintest.New(t)
+	marker := h.Path("spawned.txt")
+	err := h.Client.Spawn("sh", []string{"-c", "echo spawned > " + marker}, nil, nil)
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		data, err := os.ReadFile(marker)
+		return err == nil && string(data) == "spawned\n"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	response, err := h.Client.SpawnJob("sleep", []string{"30"}, nil, nil)
+	require.Nil(t, err)
+	require.NotEmpty(t, response.JobID)
+	require.NotZero(t, response.PID)
+	status, err := h.Client.JobStatus(response.JobID)
+	require.Nil(t, err)
+	require.Equal(t, response.PID, status.PID)
+	require.Nil(t, h.Client.KillJob(response.JobID))
+}
+
+func TestLocalJobs(t *testing.T) {
+	c := wintest.New(t).Client
+	id, err := c.StartJob("sh", []string{"-c", "cat; echo oops >&2; exit 2"}, nil, strings.NewReader("input\n"), nil)
+	require.Nil(t, err)
+	status, err := c.WaitJob(id, 5)
+	require.Nil(t, err)
+	require.False(t, status.Running)
+	require.Equal(t, 2, status.ExitCode)
+	status, err = c.JobStatus(id)
+	require.Nil(t, err)
+	require.Equal(t, id, status.ID)
+	output, err := c.JobOutput(id, 0, 0)
+	require.Nil(t, err)
+	require.Equal(t, "input\n", string(output.Stdout))
+	require.Equal(t, "oops\n", string(output.Stderr))
+	output, err = c.JobOutput(id, output.StdoutOffset, output.StderrOffset)
+	require.Nil(t, err)
+	require.Empty(t, output.Stdout)
+
+	id, err = c.StartJob("sleep", []string{"60"}, nil, nil, nil)
+	require.Nil(t, err)
+	jobs, err := c.ListJobs()
+	require.Nil(t, err)
+	require.Len(t, jobs, 2)
+	require.Nil(t, c.KillJob(id))
+	status, err = c.WaitJob(id, 5)
+	require.Nil(t, err)
+	require.False(t, status.Running)
+	_, err = c.JobStatus("nonexistent")
+	require.NotNil(t, err)
+}
+
+func TestLocalUploadDownload(t *testing.T) {
+	h := wintest.New(t)
+	c := h.Client
+	c.UploadChunkSize = 1000
+	src := filepath.Join(t.TempDir(), "data.bin")
+	data := bytes.Repeat([]byte("0123456789"), 350)
+	require.Nil(t, os.WriteFile(src, data, 0600))
+	mtime := time.Date(2021, 2, 3, 4, 5, 6, 0, time.UTC)
+	require.Nil(t, os.Chtimes(src, mtime, mtime))
+
+	// the upload is sent in chunks of a session
+	remote := h.Path("data.bin")
+	require.Nil(t, c.Upload(remote, src, false))
+	uploaded, err := os.ReadFile(remote)
+	require.Nil(t, err)
+	require.Equal(t, data, uploaded)
+	require.NotNil(t, c.Upload(remote, src, false))
+	require.Nil(t, c.Upload(remote, src, true))
+
+	dst := filepath.Join(t.TempDir(), "data.bin")
+	require.Nil(t, c.Download(dst, remote))
+	downloaded, err := os.ReadFile(dst)
+	require.Nil(t, err)
+	require.Equal(t, data, downloaded)
+	info, err := os.Stat(dst)
+	require.Nil(t, err)
+	require.True(t, mtime.Equal(info.ModTime()))
+	require.NotNil(t, c.Download(dst, h.Path("missing.bin")))
+}
+
+// trailerStripper discards trailers, as some proxies do
+type trailerStripper struct {
+	http.ResponseWriter
+	header http.Header
+}
+
+func (w *trailerStripper) Header() http.Header {
+	if w.header != nil {
+		return w.header
+	}
+	return w.ResponseWriter.Header()
+}
+
+func (w *trailerStripper) WriteHeader(status int) {
+	if w.header == nil {
+		w.ResponseWriter.Header().Del("Trailer")
+		w.header = http.Header{}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *trailerStripper) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.ResponseWriter.Write(data)
+}
+
+func TestLocalDownloadMissingTrailer(t *testing.T) {
+	h := wintest.New(t)
+	require.Nil(t, os.WriteFile(h.Path("data.txt"), []byte("data"), 0600))
+	proxy := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.Server.Handler().ServeHTTP(&trailerStripper{ResponseWriter: w}, r)
+	}))
+	proxy.TLS = h.Certs.ServerTLSConfig()
+	proxy.StartTLS()
+	defer proxy.Close()
+	url := h.URL
+	h.URL = proxy.URL
+	c, err := h.NewClient(h.ClientCert)
+	h.URL = url
+	require.Nil(t, err)
+	defer c.Close()
+	dst := filepath.Join(t.TempDir(), "data.txt")
+	err = c.Download(dst, h.Path("data.txt"))
+	require.ErrorContains(t, err, "trailer")
+	require.NoFileExists(t, dst)
+}
+
+// corruptAbort sends the first bytes of a response altered, then drops the connection
+type corruptAbort struct {
+	http.ResponseWriter
+}
+
+func (w *corruptAbort) Write(data []byte) (int, error) {
+	_, err := w.ResponseWriter.Write([]byte{^data[0], ^data[1]})
+	if err != nil {
+		return 0, err
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+	panic(http.ErrAbortHandler)
+}
+
+func TestLocalDownloadPartialSegment(t *testing.T) {
+	h := wintest.New(t)
+	require.Nil(t, os.WriteFile(h.Path("data.txt"), []byte("hello, world"), 0600))
+	var requests atomic.Int32
+	proxy := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w = &corruptAbort{ResponseWriter: w}
+		}
+		h.Server.Handler().ServeHTTP(w, r)
+	}))
+	proxy.TLS = h.Certs.ServerTLSConfig()
+	proxy.StartTLS()
+	defer proxy.Close()
+	url := h.URL
+	h.URL = proxy.URL
+	c, err := h.NewClient(h.ClientCert)
+	h.URL = url
+	require.Nil(t, err)
+	defer c.Close()
+	// the unverified bytes of the interrupted segment are fetched again
+	dst := filepath.Join(t.TempDir(), "data.txt")
+	require.Nil(t, c.Download(dst, h.Path("data.txt")))
+	data, err := os.ReadFile(dst)
+	require.Nil(t, err)
+	require.Equal(t, "hello, world", string(data))
+	require.Equal(t, int32(2), requests.Load())
+}
+
+func TestLocalTrees(t *testing.T) {
+	h := wintest.New(t)
+	c := h.Client
+	src := t.TempDir()
+	require.Nil(t, os.MkdirAll(filepath.Join(src, "sub", "empty"), 0700))
+	require.Nil(t, os.WriteFile(filepath.Join(src, "a.txt"), []byte("alpha"), 0600))
+	require.Nil(t, os.WriteFile(filepath.Join(src, "sub", "b.txt"), []byte("bravo"), 0600))
+
+	// trees are sent as archives
+	remote := h.Path("tree")
+	require.Nil(t, c.UploadTree(remote, src, false))
+	data, err := os.ReadFile(filepath.Join(remote, "sub", "b.txt"))
+	require.Nil(t, err)
+	require.Equal(t, "bravo", string(data))
+	require.DirExists(t, filepath.Join(remote, "sub", "empty"))
+
+	dst := filepath.Join(t.TempDir(), "tree")
+	require.Nil(t, c.DownloadTree(dst, remote, false))
+	data, err = os.ReadFile(filepath.Join(dst, "a.txt"))
+	require.Nil(t, err)
+	require.Equal(t, "alpha", string(data))
+	require.DirExists(t, filepath.Join(dst, "sub", "empty"))
+}
+
+func TestLocalDirectories(t *testing.T) {
+	h := wintest.New(t)
+	c := h.Client
+	require.Nil(t, c.MkdirAll(h.Path("top", "sub"), 0700))
+	require.Nil(t, c.MkdirAll(h.Path("top", "other"), 0700))
+	require.Nil(t, os.WriteFile(h.Path("top", "file.txt"), []byte("file"), 0600))
+
+	files, err := c.DirFiles(h.Path("top"))
+	require.Nil(t, err)
+	require.Equal(t, []string{"file.txt"}, files)
+	subs, err := c.DirSubs(h.Path("top"))
+	require.Nil(t, err)
+	slices.Sort(subs)
+	require.Equal(t, []string{"other", "sub"}, subs)
+	entries, err := c.DirEntries(h.Path("top"))
+	require.Nil(t, err)
+	require.Len(t, entries, 3)
+	require.Equal(t, int64(4), entries["file.txt"].Size)
+	require.True(t, entries["sub"].Mode.IsDir())
+
+	isFile, err := c.IsFile(h.Path("top", "file.txt"))
+	require.Nil(t, err)
+	require.True(t, isFile)
+	isDir, err := c.IsDir(h.Path("top", "file.txt"))
+	require.Nil(t, err)
+	require.False(t, isDir)
+	isDir, err = c.IsDir(h.Path("top", "sub"))
+	require.Nil(t, err)
+	require.True(t, isDir)
+
+	require.Nil(t, c.DeleteFile(h.Path("top", "file.txt")))
+	require.NoFileExists(t, h.Path("top", "file.txt"))
+	require.Nil(t, c.RemoveAll(h.Path("top")))
+	require.NoDirExists(t, h.Path("top"))
+}
+
+func TestLocalRenameCopy(t *testing.T) {
+	h := wintest.New(t)
+	c := h.Client
+	require.Nil(t, os.WriteFile(h.Path("a.txt"), []byte("alpha"), 0600))
+	require.Nil(t, c.Copy(h.Path("b.txt"), h.Path("a.txt"), false, false))
+	require.Nil(t, c.Rename(h.Path("c.txt"), h.Path("a.txt"), false))
+	require.NoFileExists(t, h.Path("a.txt"))
+	data, err := os.ReadFile(h.Path("b.txt"))
+	require.Nil(t, err)
+	require.Equal(t, "alpha", string(data))
+	require.NotNil(t, c.Rename(h.Path("c.txt"), h.Path("b.txt"), false))
+	require.Nil(t, c.Rename(h.Path("c.txt"), h.Path("b.txt"), true))
+	require.NoFileExists(t, h.Path("b.txt"))
+}
+
+func TestLocalFileOps(t *testing.T) {
+	h := wintest.New(t)
+	c := h.Client
+	pathname := h.Path("file.txt")
+	require.Nil(t, os.WriteFile(pathname, []byte("hello, world\n"), 0600))
+	count, err := c.WriteAt(pathname, 7, strings.NewReader("WORLD"))
+	require.Nil(t, err)
+	require.Equal(t, int64(5), count)
+	require.Nil(t, c.Truncate(pathname, 12))
+	require.Nil(t, c.Chmod(pathname, 0640))
+	require.Nil(t, c.Chown(pathname, os.Getuid(), os.Getgid()))
+	mtime := time.Date(2022, 3, 4, 5, 6, 7, 0, time.UTC)
+	require.Nil(t, c.Chtimes(pathname, mtime, mtime))
+	data, err := os.ReadFile(pathname)
+	require.Nil(t, err)
+	require.Equal(t, "hello, WORLD", string(data))
+	info, err := os.Stat(pathname)
+	require.Nil(t, err)
+	require.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	require.True(t, mtime.Equal(info.ModTime()))
+
+	require.Nil(t, c.Mkdir(h.Path("dir"), 0700))
+	require.ErrorIs(t, c.Mkdir(h.Path("dir"), 0700), fs.ErrExist)
+	require.Nil(t, c.Remove(h.Path("dir")))
+	require.Nil(t, c.Remove(pathname))
+	require.ErrorIs(t, c.Remove(pathname), fs.ErrNotExist)
+}
+
+func TestLocalHashStatWalk(t *testing.T) {
+	h := wintest.New(t)
+	c := h.Client
+	require.Nil(t, os.MkdirAll(h.Path("dir", "sub"), 0700))
+	require.Nil(t, os.WriteFile(h.Path("dir", "a.txt"), []byte("alpha"), 0600))
+	require.Nil(t, os.WriteFile(h.Path("dir", "sub", "b.log"), []byte("bravo"), 0600))
+
+	sum := sha256.Sum256([]byte("alpha"))
+	hashes, err := c.Hash(h.Path("dir"), checksum.SHA256)
+	require.Nil(t, err)
+	require.Len(t, hashes, 2)
+	require.Equal(t, hex.EncodeToString(sum[:]), hashes["a.txt"].Sums[checksum.SHA256])
+
+	stat, err := c.Stat(h.Path("dir", "a.txt"))
+	require.Nil(t, err)
+	require.Equal(t, "a.txt", stat.Name)
+	require.Equal(t, int64(5), stat.Size)
+	_, err = c.Stat(h.Path("missing"))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	names := []string{}
+	err = c.Walk(h.Path("dir"), &message.WalkOptions{Include: []string{"*.log"}}, func(pathname string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+		return err
+	})
+	require.Nil(t, err)
+	require.Equal(t, []string{"b.log"}, names)
+}
+
+func TestLocalGet(t *testing.T) {
+	h := wintest.New(t)
+	content := []byte("remote content\n")
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer source.Close()
+	sum := sha256.Sum256(content)
+	dst := h.Path("fetched.txt")
+	seconds := 0
+	err := h.Client.GetISOVerified(dst, source.URL+"/file", "", "", "", hex.EncodeToString(sum[:]), &seconds)
+	require.Nil(t, err)
+	data, err := os.ReadFile(dst)
+	require.Nil(t, err)
+	require.Equal(t, content, data)
+	err = h.Client.GetISOVerified(h.Path("bad.txt"), source.URL+"/file", "", "", "", strings.Repeat("0", 64), &seconds)
+	require.NotNil(t, err)
+	require.NoFileExists(t, h.Path("bad.txt"))
+}
+
+// the single request upload and download endpoints are not used by WinexecClient
+func TestLocalSingleRequestTransfers(t *testing.T) {
+	h := wintest.New(t)
+	httpClient, err := h.HTTPClient(h.ClientCert)
+	require.Nil(t, err)
+	post := func(path string, request any, body io.Reader, response any) int {
+		data, err := json.Marshal(request)
+		require.Nil(t, err)
+		reader := io.Reader(bytes.NewReader(data))
+		if body != nil {
+			reader = io.MultiReader(reader, strings.NewReader("\n"), body)
+		}
+		r, err := httpClient.Post(h.URL+path, "application/json", reader)
+		require.Nil(t, err)
+		defer r.Body.Close()
+		if response != nil && r.StatusCode == http.StatusOK {
+			require.Nil(t, json.NewDecoder(r.Body).Decode(response))
+		}
+		return r.StatusCode
+	}
+
+	var response message.FileResponse
+	status := post("/upload/", &message.FileUploadRequest{Pathname: h.Path("one.txt"), Content: []byte("one"), Mode: 0600}, nil, &response)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, int64(3), response.Bytes)
+	status = post("/upload/", &message.FileUploadRequest{Pathname: h.Path("one.txt"), Content: []byte("one"), Mode: 0600}, nil, nil)
+	require.Equal(t, http.StatusBadRequest, status)
+
+	status = post("/upload/stream/", &message.FileUploadRequest{Pathname: h.Path("two.txt"), Mode: 0600}, strings.NewReader("two"), &response)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, int64(3), response.Bytes)
+	data, err := os.ReadFile(h.Path("two.txt"))
+	require.Nil(t, err)
+	require.Equal(t, "two", string(data))
+
+	var download message.FileDownloadResponse
+	status = post("/download/", &message.FileDownloadRequest{Pathname: h.Path("one.txt")}, nil, &download)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "one", string(download.Content))
+
+	// an upload session may be queried and abandoned
+	var session message.UploadSessionResponse
+	status = post("/upload/session/", &message.UploadSessionRequest{Pathname: h.Path("three.txt"), Size: 5, Mode: 0600}, nil, &session)
+	require.Equal(t, http.StatusOK, status)
+	status = post("/upload/chunk/", &message.UploadRequest{SessionID: session.SessionID}, strings.NewReader("thr"), &session)
+	require.Equal(t, http.StatusOK, status)
+	status = post("/upload/status/", &message.UploadRequest{SessionID: session.SessionID}, nil, &session)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, int64(3), session.Offset)
+	status = post("/upload/abort/", &message.UploadRequest{SessionID: session.SessionID}, nil, &session)
+	require.Equal(t, http.StatusOK, status)
+	status = post("/upload/status/", &message.UploadRequest{SessionID: session.SessionID}, nil, nil)
+	require.Equal(t, http.StatusNotFound, status)
+	require.NoFileExists(t, h.Path("three.txt"))
+
+	// a client certificate from another CA is refused
+	other, err := wintest.NewCerts()
+	require.Nil(t, err)
+	stranger, err := other.NewClientCert("stranger")
+	require.Nil(t, err)
+	config, err := other.ClientTLSConfig(stranger)
+	require.Nil(t, err)
+	config.RootCAs = h.Server.TLSConfig.ClientCAs
+	strangerClient := http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	_, err = strangerClient.Get(h.URL + "/ping/")
+	require.NotNil(t, err)
+}
+
+func TestLocalSandbox(t *testing.T) {
+	h := wintest.New(t, wintest.Sandboxed)
+	c := h.Client
+	outside := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0600))
+	require.Nil(t, os.WriteFile(h.Path("a.txt"), []byte("alpha"), 0600))
+
+	_, err := c.Stat(h.Path("a.txt"))
+	require.Nil(t, err)
+	_, err = c.Stat(filepath.Join(outside, "secret"))
+	require.ErrorIs(t, err, client.ErrPathRejected)
+	require.ErrorIs(t, c.Remove(filepath.Join(outside, "secret")), client.ErrPathRejected)
+	require.ErrorIs(t, c.Rename(filepath.Join(outside, "b.txt"), h.Path("a.txt"), false), client.ErrPathRejected)
+	require.ErrorIs(t, c.Remove(h.Dir+"/../sandbox/a.txt"), client.ErrPathRejected)
+	if runtime.GOOS != "windows" {
+		require.Nil(t, os.Symlink(outside, h.Path("escape")))
+		_, err = c.Stat(h.Path("escape", "secret"))
+		require.ErrorIs(t, err, client.ErrPathRejected)
+	}
+	require.FileExists(t, filepath.Join(outside, "secret"))
+}
+
+func TestLocalPolicy(t *testing.T) {
+	h := wintest.New(t, func(h *wintest.Harness, s *server.WinexecServer) {
+		s.Policies = []server.Policy{
+			{Name: "admin", CommonNames: []string{"winexec test client"}},
+			{Name: "netboot", OrganizationalUnits: []string{"netboot"}, Endpoints: []string{"/ping/", "/stat/"}, ReadOnlyRoots: []string{h.Dir}},
+		}
+	})
+	require.Nil(t, os.WriteFile(h.Path("boot.conf"), []byte("boot"), 0600))
+	cert, err := h.Certs.NewClientCert("netboot client", "netboot")
+	require.Nil(t, err)
+	netboot, err := h.NewClient(cert)
+	require.Nil(t, err)
+	defer netboot.Close()
+
+	require.Nil(t, netboot.Ping())
+	_, err = netboot.Stat(h.Path("boot.conf"))
+	require.Nil(t, err)
+	_, err = netboot.Stat(os.TempDir())
+	require.ErrorIs(t, err, client.ErrPathRejected)
+	require.ErrorIs(t, netboot.Remove(h.Path("boot.conf")), fs.ErrPermission)
+	_, _, err = netboot.Exec("echo", []string{"hello"}, nil, nil, nil, nil)
+	require.NotNil(t, err)
+
+	_, _, err = h.Client.Exec("echo", []string{"hello"}, nil, nil, nil, nil)
+	require.Nil(t, err)
+	_, err = h.Client.Stat(os.TempDir())
+	require.Nil(t, err)
+
+	cert, err = h.Certs.NewClientCert("stranger")
+	require.Nil(t, err)
+	stranger, err := h.NewClient(cert)
+	require.Nil(t, err)
+	defer stranger.Close()
+	require.NotNil(t, stranger.Ping())
+}
+
+func TestLocalCommandRules(t *testing.T) {
+	h := wintest.New(t, func(h *wintest.Harness, s *server.WinexecServer) {
+		s.EnforceCommandRules = true
+		s.CommandRules = []server.CommandRule{{Name: "echo", Command: "echo", ExtraArgs: true, Dir: h.Dir}}
+	})
+	c := h.Client
+	stdout, _, err := c.Exec("echo", []string{"hello"}, nil, nil, nil, nil)
+	require.Nil(t, err)
+	require.Equal(t, "hello\n", stdout)
+	_, _, err = c.Exec("true", nil, nil, nil, nil, nil)
+	require.NotNil(t, err)
+
+	result, err := c.CheckCommand("echo", []string{"hello"}, nil, nil)
+	require.Nil(t, err)
+	require.True(t, result.Allowed)
+	require.Equal(t, "echo", result.Rule)
+	require.Equal(t, h.Dir, result.Dir)
+	result, err = c.CheckCommand("true", nil, nil, nil)
+	require.Nil(t, err)
+	require.False(t, result.Allowed)
+}
+
+func TestLocalAudit(t *testing.T) {
+	h := wintest.New(t)
+	c := h.Client
+	require.Nil(t, os.WriteFile(h.Path("a.txt"), []byte("alpha"), 0600))
+	require.Nil(t, c.Download(filepath.Join(t.TempDir(), "a.txt"), h.Path("a.txt")))
+	_, _, err := c.Exec("sh", []string{"-c", "exit 2"}, nil, nil, nil, nil)
+	require.NotNil(t, err)
+
+	entries, err := c.Audit(&message.AuditQueryRequest{Path: "a.txt"})
+	require.Nil(t, err)
+	require.NotEmpty(t, entries)
+	entry := entries[len(entries)-1]
+	require.Equal(t, "winexec test client", entry.Client)
+	require.NotEmpty(t, entry.Fingerprint)
+	require.Equal(t, []string{h.Path("a.txt")}, entry.Paths)
+	require.Equal(t, http.StatusOK, entry.Status)
+	require.GreaterOrEqual(t, entry.BytesOut, int64(len("alpha")))
+
+	entries, err = c.Audit(&message.AuditQueryRequest{Command: "sh", Failed: true})
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, 2, *entries[0].ExitCode)
+
+	entries, err = c.Audit(&message.AuditQueryRequest{Client: "someone else"})
+	require.Nil(t, err)
+	require.Empty(t, entries)
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/rstms/winexec/checksum"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// transient errors are network timeouts, connections reset or closed mid-response,
+// gateway errors and corrupt transfers; all other errors are permanent
+func isTransient(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	// context errors satisfy net.Error, but a cancelled request is not retried
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "read" {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, checksum.ErrMismatch)
+}
+
+// call fn until it succeeds, fails with a permanent error, or the retries are exhausted
+func (c *WinexecClient) retry(label string, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !isTransient(err) || attempt >= c.Retries {
+			return err
+		}
+		delay := time.Duration(c.RetryDelaySeconds*(attempt+1)) * time.Second
+		log.Printf("winexec %s failed, retrying in %v: %v\n", label, delay, err)
+		time.Sleep(delay)
+	}
+}
+
+// post a request with an optional data stream and decode the JSON response;
+// unlike the APIClient methods, the returned error preserves StatusError
+func (c *WinexecClient) post(path string, request any, body io.Reader, response any) error {
+	stream, err := c.stream(path, request, body)
+	if err != nil {
+		return err
+	}
+	defer stream.Body.Close()
+	err = json.NewDecoder(stream.Body).Decode(response)
+	if err != nil {
+		return err
+	}
+	// the body is drained so the connection can be reused
+	_, err = io.Copy(io.Discard, stream.Body)
+	return err
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"encoding/json"
+	"github.com/rstms/winexec/message"
+	"io"
+	"log"
+)
+
+const SHELL_INPUT_BUFFER_SIZE = 4096
+
+type WindowSize struct {
+	Rows int
+	Cols int
+}
+
+// run an interactive session, forwarding stdin and window size changes to the remote
+// process until it exits; when request.Term is set the server attaches the process to
+// a pseudo-terminal if it can, and all output is written to stdout
+func (c *WinexecClient) Shell(request *message.ShellRequest, stdin io.Reader, stdout, stderr io.Writer, resize <-chan WindowSize, exitCode *int) error {
+	if c.debug {
+		log.Printf("winexec Shell(%s %v)\n", request.Command, request.Args)
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	if c.debug {
+		log.Printf("winexec shell request: %s\n", message.Redacted(request))
+	}
+	reader, writer := io.Pipe()
+	// closing the reader stops the input goroutine once the session ends
+	defer reader.Close()
+	go sendShellFrames(writer, stdin, resize)
+	response, err := c.stream("/shell/", request, reader)
+	if err != nil {
+		return Fatal(err)
+	}
+	defer response.Body.Close()
+	command := request.Command
+	if command == "" {
+		command = "shell"
+	}
+	return c.receiveFrames(response.Body, command, request.TimeoutSeconds, stdout, stderr, exitCode)
+}
+
+// the request body ends when stdin does, closing the input of a process that is not
+// attached to a terminal
+func sendShellFrames(writer *io.PipeWriter, stdin io.Reader, resize <-chan WindowSize) {
+	done := make(chan struct{})
+	defer close(done)
+	input := make(chan []byte)
+	go func() {
+		defer close(input)
+		if stdin == nil {
+			return
+		}
+		buf := make([]byte, SHELL_INPUT_BUFFER_SIZE)
+		for {
+			count, err := stdin.Read(buf)
+			if count > 0 {
+				data := make([]byte, count)
+				copy(data, buf[:count])
+				select {
+				case input <- data:
+				case <-done:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	encoder := json.NewEncoder(writer)
+	for {
+		var err error
+		select {
+		case data, ok := <-input:
+			if !ok {
+				writer.Close()
+				return
+			}
+			err = encoder.Encode(&message.ShellFrame{Stream: message.SHELL_STDIN, Data: data})
+		case size, ok := <-resize:
+			if !ok {
+				resize = nil
+				continue
+			}
+			err = encoder.Encode(&message.ShellFrame{Stream: message.SHELL_RESIZE, Rows: size.Rows, Cols: size.Cols})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
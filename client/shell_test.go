@@ -0,0 +1,43 @@
+package client_test
+
+import (
+	"bytes"
+	"github.com/rstms/winexec/client"
+	"github.com/rstms/winexec/message"
+	"github.com/rstms/winexec/wintest"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestShell(t *testing.T) {
+	h := wintest.New(t)
+	c := h.Client
+	request := message.ShellRequest{Command: "sh"}
+	stdin := strings.NewReader("echo howdy\necho oops >&2\nexit 3\n")
+	var stdout, stderr bytes.Buffer
+	var exitCode int
+	err := c.Shell(&request, stdin, &stdout, &stderr, nil, &exitCode)
+	require.Nil(t, err)
+	require.Equal(t, "howdy\n", stdout.String())
+	require.Equal(t, "oops\n", stderr.String())
+	require.Equal(t, 3, exitCode)
+
+	// a terminal session returns all output on stdout
+	request = message.ShellRequest{Command: "sh", Term: "dumb", Rows: 24, Cols: 80}
+	resize := make(chan client.WindowSize)
+	input, writer := io.Pipe()
+	go func() {
+		resize <- client.WindowSize{Rows: 50, Cols: 120}
+		writer.Write([]byte("stty size >&2; exit\n"))
+		writer.Close()
+	}()
+	stdout.Reset()
+	stderr.Reset()
+	err = c.Shell(&request, input, &stdout, &stderr, resize, &exitCode)
+	require.Nil(t, err)
+	require.Contains(t, stdout.String(), "50 120")
+	require.Empty(t, stderr.String())
+	require.Equal(t, 0, exitCode)
+}
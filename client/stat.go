@@ -0,0 +1,49 @@
+package client
+
+import (
+	"errors"
+	"github.com/rstms/winexec/message"
+	"io/fs"
+	"log"
+	"net/http"
+)
+
+// return file information for a remote path without following symlinks; a missing
+// file is reported as an *fs.PathError wrapping fs.ErrNotExist
+func (c *WinexecClient) Stat(pathname string) (*message.FileStat, error) {
+	return c.stat(pathname, false)
+}
+
+// with follow, a symlink's target is described, as by os.Stat
+func (c *WinexecClient) stat(pathname string, follow bool) (*message.FileStat, error) {
+	if c.debug {
+		log.Printf("winexec Stat(%s, %v)\n", pathname, follow)
+	}
+	request := message.StatRequest{
+		Pathname: pathname,
+		Follow:   follow,
+	}
+	if c.debug {
+		log.Printf("winexec stat request: %s\n", message.Redacted(request))
+	}
+	var response message.StatResponse
+	err := c.post("/stat/", &request, nil, &response)
+	if err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			// not wrapped with Fatal so callers can test it with errors.Is
+			return nil, &fs.PathError{Op: "stat", Path: pathname, Err: fs.ErrNotExist}
+		}
+		if errors.Is(err, ErrPathRejected) {
+			return nil, &fs.PathError{Op: "stat", Path: pathname, Err: ErrPathRejected}
+		}
+		return nil, Fatal(err)
+	}
+	if c.debug {
+		log.Printf("winexec stat response: %s\n", message.Redacted(response))
+	}
+	if !response.Success {
+		return nil, Fatalf("WinExec: stat failed: %v", response)
+	}
+	return &response.Stat, nil
+}
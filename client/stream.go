@@ -0,0 +1,167 @@
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rstms/winexec/message"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// StatusError is returned when the server rejects a streaming request
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Status, e.Message)
+}
+
+// ErrPathRejected is returned when a path is outside the server's sandbox roots
+var ErrPathRejected = errors.New("path rejected by server sandbox")
+
+// ErrTimedOut is returned when a process is killed on timeout; the error is not wrapped
+// with Fatal so callers can use errors.Is, and the exit code is set as for any exit
+var ErrTimedOut = errors.New("timed out")
+
+func (e *StatusError) Is(target error) bool {
+	return target == ErrPathRejected && e.StatusCode == message.STATUS_PATH_REJECTED
+}
+
+// the go-common APIClient buffers whole bodies, so streaming requests use a separate http.Client
+func newHTTPClient(caFile, certFile, keyFile string) (*http.Client, error) {
+	transport := http.Transport{}
+	if certFile != "" || keyFile != "" || caFile != "" {
+		if certFile == "" || keyFile == "" || caFile == "" {
+			return nil, Fatalf("incomplete TLS config: cert=%s key=%s ca=%s\n", certFile, keyFile, caFile)
+		}
+		cert, err := tls.LoadX509KeyPair(os.ExpandEnv(certFile), os.ExpandEnv(keyFile))
+		if err != nil {
+			return nil, Fatalf("error loading client certificate pair: %v", err)
+		}
+		caCert, err := os.ReadFile(os.ExpandEnv(caFile))
+		if err != nil {
+			return nil, Fatalf("error loading certificate authority file: %v", err)
+		}
+		caCertPool, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, Fatalf("error opening system certificate pool: %v", err)
+		}
+		caCertPool.AppendCertsFromPEM(caCert)
+		transport.TLSClientConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			RootCAs:      caCertPool,
+		}
+	}
+	return &http.Client{Transport: &transport}, nil
+}
+
+// post a JSON request line followed by an optional data stream and return the
+// open response; the caller must close the response body
+func (c *WinexecClient) stream(path string, request any, body io.Reader) (*http.Response, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	contentType := "application/json"
+	var reader io.Reader = bytes.NewReader(data)
+	if body != nil {
+		contentType = "application/octet-stream"
+		reader = io.MultiReader(reader, strings.NewReader("\n"), body)
+	}
+	response, err := c.http.Post(c.url+path, contentType, reader)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		defer response.Body.Close()
+		statusErr := StatusError{StatusCode: response.StatusCode, Status: response.Status}
+		var failure message.FailResponse
+		body, err := io.ReadAll(response.Body)
+		if err == nil && json.Unmarshal(body, &failure) == nil {
+			statusErr.Message = failure.Message
+		} else {
+			statusErr.Message = string(bytes.TrimSpace(body))
+		}
+		return nil, &statusErr
+	}
+	return response, nil
+}
+
+func (c *WinexecClient) ExecStream(command string, args, env []string, stdin io.Reader, stdout, stderr io.Writer, options *message.ProcessOptions, exitCode *int) error {
+	if c.debug {
+		log.Printf("winexec ExecStream(%s %v)\n", command, args)
+	}
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	request := message.ExecRequest{Command: command, Args: args, Env: env}
+	if options != nil {
+		request.ProcessOptions = *options
+	}
+	if c.debug {
+		log.Printf("winexec exec stream request: %s\n", message.Redacted(request))
+	}
+	response, err := c.stream("/exec/stream/", &request, stdin)
+	if err != nil {
+		return Fatal(err)
+	}
+	defer response.Body.Close()
+	return c.receiveFrames(response.Body, command, request.TimeoutSeconds, stdout, stderr, exitCode)
+}
+
+// write output frames until the exit frame, which sets exitCode if it is not nil
+func (c *WinexecClient) receiveFrames(body io.Reader, command string, timeoutSeconds int, stdout, stderr io.Writer, exitCode *int) error {
+	decoder := json.NewDecoder(body)
+	for {
+		var frame message.ExecFrame
+		err := decoder.Decode(&frame)
+		if err == io.EOF {
+			return Fatalf("WinExec: exec stream ended without exit status")
+		}
+		if err != nil {
+			return Fatal(err)
+		}
+		switch frame.Stream {
+		case message.EXEC_STDOUT:
+			_, err = stdout.Write(frame.Data)
+		case message.EXEC_STDERR:
+			_, err = stderr.Write(frame.Data)
+		case message.EXEC_EXIT:
+			if c.debug {
+				log.Printf("winexec exec stream exit: %s\n", message.Redacted(frame))
+			}
+			if frame.TimedOut {
+				if exitCode != nil {
+					*exitCode = frame.ExitCode
+				}
+				return fmt.Errorf("Process '%s' %w after %d seconds", command, ErrTimedOut, timeoutSeconds)
+			}
+			if exitCode != nil {
+				*exitCode = frame.ExitCode
+			} else if frame.ExitCode != 0 {
+				return Fatalf("Process '%s' exited %d", command, frame.ExitCode)
+			}
+			return nil
+		case message.EXEC_ERROR:
+			return Fatalf("WinExec: exec stream failed: %s", frame.Message)
+		default:
+			return Fatalf("WinExec: unexpected exec stream frame: %s", message.Redacted(frame))
+		}
+		if err != nil {
+			return Fatal(err)
+		}
+	}
+}
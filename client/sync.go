@@ -0,0 +1,316 @@
+package client
+
+import (
+	"github.com/rstms/winexec/checksum"
+	"github.com/rstms/winexec/message"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const SYNC_DIR_MODE = 0755
+
+type SyncOptions struct {
+	Delete   bool // remove destination files and directories not present in the source
+	DryRun   bool // report what would change without changing anything
+	Checksum bool // compare files of equal size by SHA256 digest instead of modification time
+}
+
+// pathnames are slash separated and relative to the sync directories
+type SyncReport struct {
+	DryRun      bool
+	Directories []string
+	Created     []string
+	Updated     []string
+	Deleted     []string
+	Unchanged   []string
+	Bytes       int64
+}
+
+type syncEntry struct {
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+}
+
+// one side of a sync; list returns false if the root directory does not exist
+type syncTree struct {
+	list   func() (map[string]syncEntry, bool, error)
+	hash   func() (map[string]message.FileHash, error)
+	mkdir  func(name string) error
+	remove func(name string, isDir bool) error
+}
+
+// update remoteDir to match localDir, transferring only files that differ
+func (c *WinexecClient) SyncUp(localDir, remoteDir string, options SyncOptions) (*SyncReport, error) {
+	if c.debug {
+		log.Printf("winexec SyncUp(%s, %s, %s)\n", localDir, remoteDir, message.Redacted(options))
+	}
+	copyFile := func(name string) error {
+		return c.Upload(path.Join(remoteDir, name), filepath.Join(localDir, filepath.FromSlash(name)), true)
+	}
+	report, err := syncTrees(localTree(localDir), c.remoteTree(remoteDir), copyFile, options)
+	if err != nil {
+		return report, Fatal(err)
+	}
+	return report, nil
+}
+
+// update localDir to match remoteDir, transferring only files that differ
+func (c *WinexecClient) SyncDown(localDir, remoteDir string, options SyncOptions) (*SyncReport, error) {
+	if c.debug {
+		log.Printf("winexec SyncDown(%s, %s, %s)\n", localDir, remoteDir, message.Redacted(options))
+	}
+	copyFile := func(name string) error {
+		return c.Download(filepath.Join(localDir, filepath.FromSlash(name)), path.Join(remoteDir, name))
+	}
+	report, err := syncTrees(c.remoteTree(remoteDir), localTree(localDir), copyFile, options)
+	if err != nil {
+		return report, Fatal(err)
+	}
+	return report, nil
+}
+
+func syncTrees(src, dst *syncTree, copyFile func(name string) error, options SyncOptions) (*SyncReport, error) {
+	report := SyncReport{DryRun: options.DryRun}
+	srcEntries, exists, err := src.list()
+	if err != nil {
+		return &report, err
+	}
+	if !exists {
+		return &report, Fatalf("sync source directory not found")
+	}
+	dstEntries, exists, err := dst.list()
+	if err != nil {
+		return &report, err
+	}
+	if !exists && !options.DryRun {
+		err := dst.mkdir("")
+		if err != nil {
+			return &report, err
+		}
+	}
+
+	var srcHashes, dstHashes map[string]message.FileHash
+	if options.Checksum {
+		srcHashes, err = src.hash()
+		if err != nil {
+			return &report, err
+		}
+		if exists {
+			dstHashes, err = dst.hash()
+			if err != nil {
+				return &report, err
+			}
+		}
+	}
+
+	// a destination entry of the wrong type is removed before it is replaced
+	removed := []string{}
+	remove := func(name string, isDir bool) error {
+		report.Deleted = append(report.Deleted, name)
+		removed = append(removed, name)
+		if options.DryRun {
+			return nil
+		}
+		return dst.remove(name, isDir)
+	}
+	isRemoved := func(name string) bool {
+		for _, parent := range removed {
+			if strings.HasPrefix(name, parent+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	names := sortedNames(srcEntries)
+	for _, name := range names {
+		entry := srcEntries[name]
+		dstEntry, found := dstEntries[name]
+		if found && isRemoved(name) {
+			found = false
+		}
+		if found && dstEntry.IsDir != entry.IsDir {
+			err := remove(name, dstEntry.IsDir)
+			if err != nil {
+				return &report, err
+			}
+			found = false
+		}
+		if entry.IsDir {
+			if !found {
+				report.Directories = append(report.Directories, name)
+				if !options.DryRun {
+					err := dst.mkdir(name)
+					if err != nil {
+						return &report, err
+					}
+				}
+			}
+			continue
+		}
+		if found && sameFile(name, entry, dstEntry, srcHashes, dstHashes, options.Checksum) {
+			report.Unchanged = append(report.Unchanged, name)
+			continue
+		}
+		if found {
+			report.Updated = append(report.Updated, name)
+		} else {
+			report.Created = append(report.Created, name)
+		}
+		report.Bytes += entry.Size
+		if !options.DryRun {
+			err := copyFile(name)
+			if err != nil {
+				return &report, err
+			}
+		}
+	}
+
+	if options.Delete {
+		for _, name := range sortedNames(dstEntries) {
+			_, found := srcEntries[name]
+			if found || isRemoved(name) {
+				continue
+			}
+			err := remove(name, dstEntries[name].IsDir)
+			if err != nil {
+				return &report, err
+			}
+		}
+	}
+	return &report, nil
+}
+
+// parent directories sort before their contents
+func sortedNames(entries map[string]syncEntry) []string {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		return slices.Compare(strings.Split(a, "/"), strings.Split(b, "/"))
+	})
+	return names
+}
+
+// modification times are compared to the second to allow for filesystem resolution
+func sameFile(name string, src, dst syncEntry, srcHashes, dstHashes map[string]message.FileHash, compareHash bool) bool {
+	if src.Size != dst.Size {
+		return false
+	}
+	if compareHash {
+		srcSum := srcHashes[name].Sums[checksum.SHA256]
+		return srcSum != "" && srcSum == dstHashes[name].Sums[checksum.SHA256]
+	}
+	return src.ModTime.Unix() == dst.ModTime.Unix()
+}
+
+// symlinks and other special files are not synchronized
+func localTree(root string) *syncTree {
+	return &syncTree{
+		list: func() (map[string]syncEntry, bool, error) {
+			entries := make(map[string]syncEntry)
+			fileinfo, err := os.Stat(root)
+			if os.IsNotExist(err) {
+				return entries, false, nil
+			}
+			if err != nil {
+				return nil, false, err
+			}
+			if !fileinfo.IsDir() {
+				return nil, false, Fatalf("not a directory: %s", root)
+			}
+			err = filepath.WalkDir(root, func(pathname string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if pathname == root || !(entry.IsDir() || entry.Type().IsRegular()) {
+					return nil
+				}
+				info, err := entry.Info()
+				if err != nil {
+					return err
+				}
+				name, err := filepath.Rel(root, pathname)
+				if err != nil {
+					return err
+				}
+				entries[filepath.ToSlash(name)] = syncEntry{IsDir: entry.IsDir(), Size: info.Size(), ModTime: info.ModTime()}
+				return nil
+			})
+			if err != nil {
+				return nil, false, err
+			}
+			return entries, true, nil
+		},
+		hash: func() (map[string]message.FileHash, error) {
+			files, err := checksum.Path(root, checksum.SHA256)
+			if err != nil {
+				return nil, err
+			}
+			hashes := make(map[string]message.FileHash)
+			for name, sums := range files {
+				hashes[name] = message.FileHash(sums)
+			}
+			return hashes, nil
+		},
+		mkdir: func(name string) error {
+			return os.MkdirAll(filepath.Join(root, filepath.FromSlash(name)), SYNC_DIR_MODE)
+		},
+		remove: func(name string, isDir bool) error {
+			return os.RemoveAll(filepath.Join(root, filepath.FromSlash(name)))
+		},
+	}
+}
+
+func (c *WinexecClient) remoteTree(root string) *syncTree {
+	return &syncTree{
+		list: func() (map[string]syncEntry, bool, error) {
+			entries := make(map[string]syncEntry)
+			isDir, err := c.IsDir(root)
+			if err != nil || !isDir {
+				return entries, false, err
+			}
+			// Walk joins root with each relative path, which cleans root
+			prefix := strings.TrimSuffix(path.Clean(root), "/") + "/"
+			err = c.Walk(root, nil, func(pathname string, entry fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				name, found := strings.CutPrefix(pathname, prefix)
+				if !found || !(entry.IsDir() || entry.Type().IsRegular()) {
+					return nil
+				}
+				info, err := entry.Info()
+				if err != nil {
+					return err
+				}
+				entries[name] = syncEntry{IsDir: entry.IsDir(), Size: info.Size(), ModTime: info.ModTime()}
+				return nil
+			})
+			if err != nil {
+				return nil, false, err
+			}
+			return entries, true, nil
+		},
+		hash: func() (map[string]message.FileHash, error) {
+			return c.Hash(root, checksum.SHA256)
+		},
+		mkdir: func(name string) error {
+			return c.MkdirAll(path.Join(root, name), SYNC_DIR_MODE)
+		},
+		remove: func(name string, isDir bool) error {
+			if isDir {
+				return c.RemoveAll(path.Join(root, name))
+			}
+			return c.DeleteFile(path.Join(root, name))
+		},
+	}
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeSyncFile(t *testing.T, root, name, content string, timestamp time.Time) {
+	pathname := filepath.Join(root, filepath.FromSlash(name))
+	require.Nil(t, os.MkdirAll(filepath.Dir(pathname), 0700))
+	require.Nil(t, os.WriteFile(pathname, []byte(content), 0600))
+	require.Nil(t, os.Chtimes(pathname, timestamp, timestamp))
+}
+
+// sync between two local trees, copying with the source timestamp as Upload and Download do
+func localSync(t *testing.T, src, dst string, options SyncOptions) *SyncReport {
+	copyFile := func(name string) error {
+		srcPathname := filepath.Join(src, filepath.FromSlash(name))
+		dstPathname := filepath.Join(dst, filepath.FromSlash(name))
+		in, err := os.Open(srcPathname)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.Create(dstPathname)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, in)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		err = out.Close()
+		if err != nil {
+			return err
+		}
+		fileinfo, err := in.Stat()
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(dstPathname, fileinfo.ModTime(), fileinfo.ModTime())
+	}
+	report, err := syncTrees(localTree(src), localTree(dst), copyFile, options)
+	require.Nil(t, err)
+	return report
+}
+
+func TestSync(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	dst := filepath.Join(t.TempDir(), "dst")
+	then := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	writeSyncFile(t, src, "a.txt", "alpha", then)
+	writeSyncFile(t, src, "sub/b.txt", "bravo", then)
+	require.Nil(t, os.MkdirAll(filepath.Join(src, "empty"), 0700))
+
+	report := localSync(t, src, dst, SyncOptions{DryRun: true})
+	require.Equal(t, []string{"a.txt", "sub/b.txt"}, report.Created)
+	require.Equal(t, []string{"empty", "sub"}, report.Directories)
+	require.Equal(t, int64(10), report.Bytes)
+	require.NoDirExists(t, dst)
+
+	report = localSync(t, src, dst, SyncOptions{})
+	require.Equal(t, []string{"a.txt", "sub/b.txt"}, report.Created)
+	require.DirExists(t, filepath.Join(dst, "empty"))
+	data, err := os.ReadFile(filepath.Join(dst, "sub", "b.txt"))
+	require.Nil(t, err)
+	require.Equal(t, "bravo", string(data))
+
+	report = localSync(t, src, dst, SyncOptions{})
+	require.Empty(t, report.Created)
+	require.Empty(t, report.Updated)
+	require.Equal(t, []string{"a.txt", "sub/b.txt"}, report.Unchanged)
+
+	// a changed timestamp is an update unless the digests are compared
+	writeSyncFile(t, src, "a.txt", "alpha", then.Add(time.Hour))
+	report = localSync(t, src, dst, SyncOptions{Checksum: true, DryRun: true})
+	require.Empty(t, report.Updated)
+	report = localSync(t, src, dst, SyncOptions{})
+	require.Equal(t, []string{"a.txt"}, report.Updated)
+
+	// equal size and time with different content is only detected by digest
+	writeSyncFile(t, src, "sub/b.txt", "BRAVO", then)
+	report = localSync(t, src, dst, SyncOptions{})
+	require.Empty(t, report.Updated)
+	report = localSync(t, src, dst, SyncOptions{Checksum: true})
+	require.Equal(t, []string{"sub/b.txt"}, report.Updated)
+
+	// extras are deleted only when requested, and a directory replaces a file
+	writeSyncFile(t, dst, "extra/c.txt", "charlie", then)
+	writeSyncFile(t, dst, "d", "file", then)
+	writeSyncFile(t, src, "d/e.txt", "echo", then)
+	report = localSync(t, src, dst, SyncOptions{})
+	require.Equal(t, []string{"d"}, report.Deleted)
+	require.Equal(t, []string{"d/e.txt"}, report.Created)
+	require.FileExists(t, filepath.Join(dst, "extra", "c.txt"))
+	report = localSync(t, src, dst, SyncOptions{Delete: true, DryRun: true})
+	require.Equal(t, []string{"extra"}, report.Deleted)
+	require.FileExists(t, filepath.Join(dst, "extra", "c.txt"))
+	report = localSync(t, src, dst, SyncOptions{Delete: true})
+	require.Equal(t, []string{"extra"}, report.Deleted)
+	require.NoDirExists(t, filepath.Join(dst, "extra"))
+
+	_, err = syncTrees(localTree(filepath.Join(src, "missing")), localTree(dst), nil, SyncOptions{})
+	require.NotNil(t, err)
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/rstms/winexec/message"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// walk the remote tree at root, calling fn for each entry the server selects as
+// filepath.WalkDir does; paths passed to fn are root joined with the slash separated
+// relative path, and the fs.DirEntry Info Sys method returns the *message.FileStat;
+// returning fs.SkipDir from fn skips the rest of a directory, and fs.SkipAll ends the walk
+func (c *WinexecClient) Walk(root string, options *message.WalkOptions, fn fs.WalkDirFunc) error {
+	if c.debug {
+		log.Printf("winexec Walk(%s, %s)\n", root, message.Redacted(options))
+	}
+	request := message.WalkRequest{Pathname: root}
+	if options != nil {
+		request.WalkOptions = *options
+	}
+	stream, err := c.stream("/walk/", &request, nil)
+	if err != nil {
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			err = &fs.PathError{Op: "lstat", Path: root, Err: fs.ErrNotExist}
+		} else if errors.Is(err, ErrPathRejected) {
+			err = &fs.PathError{Op: "lstat", Path: root, Err: ErrPathRejected}
+		} else {
+			err = Fatal(err)
+		}
+		err = fn(root, nil, err)
+		if err == fs.SkipDir || err == fs.SkipAll {
+			return nil
+		}
+		return err
+	}
+	defer stream.Body.Close()
+	decoder := json.NewDecoder(stream.Body)
+	skipped := ""
+	for {
+		var frame message.WalkFrame
+		err := decoder.Decode(&frame)
+		if err == io.EOF {
+			return Fatalf("WinExec: walk stream ended without completion")
+		}
+		if err != nil {
+			return Fatal(err)
+		}
+		if frame.Done {
+			return nil
+		}
+		if frame.Path == "" {
+			return Fatalf("WinExec: walk failed: %s", frame.Error)
+		}
+		if skipped != "" && strings.HasPrefix(frame.Path, skipped+"/") {
+			continue
+		}
+		pathname := path.Join(root, frame.Path)
+		entry := &fileInfo{stat: frame.Stat}
+		var entryErr error
+		if frame.Error != "" {
+			entryErr = &fs.PathError{Op: "readdir", Path: pathname, Err: errors.New(frame.Error)}
+		}
+		err = fn(pathname, entry, entryErr)
+		switch {
+		case err == fs.SkipDir && entry.IsDir():
+			skipped = frame.Path
+			if frame.Path == "." {
+				return nil
+			}
+		case err == fs.SkipDir:
+			// skip the remaining entries in the parent directory
+			skipped = path.Dir(frame.Path)
+			if skipped == "." {
+				return nil
+			}
+		case err == fs.SkipAll:
+			return nil
+		case err != nil:
+			return err
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"github.com/rstms/winexec/ospath"
+	"golang.org/x/net/webdav"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// WebDAV returns a webdav.FileSystem presenting the remote directory root; when
+// readOnly is set, any operation that would change the remote filesystem fails
+// with fs.ErrPermission
+func (c *WinexecClient) WebDAV(root string, readOnly bool) webdav.FileSystem {
+	return &webdavFS{fs: c.AferoFs(), root: ospath.UnixPath(root), readOnly: readOnly}
+}
+
+// WebDAVHandler returns an http.Handler serving the remote directory root; when readOnly
+// is set, requests that could change the remote filesystem are rejected with 403 Forbidden,
+// since the webdav handler reports some failures to open a file as 404 Not Found
+func (c *WinexecClient) WebDAVHandler(root string, readOnly bool, logger func(*http.Request, error)) http.Handler {
+	handler := webdav.Handler{
+		FileSystem: c.WebDAV(root, readOnly),
+		LockSystem: webdav.NewMemLS(),
+		Logger:     logger,
+	}
+	if !readOnly {
+		return &handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND":
+			handler.ServeHTTP(w, r)
+		default:
+			if logger != nil {
+				logger(r, &fs.PathError{Op: r.Method, Path: r.URL.Path, Err: fs.ErrPermission})
+			}
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		}
+	})
+}
+
+type webdavFS struct {
+	fs       *AferoFs
+	root     string
+	readOnly bool
+}
+
+// webdav names are slash separated and rooted; backslashes are rejected since
+// ospath would map them to separators on the server
+func (w *webdavFS) pathname(op, name string) (string, error) {
+	if strings.Contains(name, `\`) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(w.root, path.Clean("/"+name)), nil
+}
+
+func (w *webdavFS) writable(op, name string) (string, error) {
+	if w.readOnly {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrPermission}
+	}
+	return w.pathname(op, name)
+}
+
+func (w *webdavFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	pathname, err := w.writable("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return w.fs.Mkdir(pathname, perm)
+}
+
+func (w *webdavFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	var pathname string
+	var err error
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 {
+		pathname, err = w.writable("open", name)
+	} else {
+		pathname, err = w.pathname("open", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return w.fs.OpenFile(pathname, flag, perm)
+}
+
+func (w *webdavFS) RemoveAll(ctx context.Context, name string) error {
+	pathname, err := w.writable("remove", name)
+	if err != nil {
+		return err
+	}
+	// the handler never removes the root, but a remote root must not be lost to a bad request
+	if pathname == w.root {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrPermission}
+	}
+	return w.fs.RemoveAll(pathname)
+}
+
+func (w *webdavFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldPathname, err := w.writable("rename", oldName)
+	if err != nil {
+		return err
+	}
+	newPathname, err := w.writable("rename", newName)
+	if err != nil {
+		return err
+	}
+	return w.fs.Rename(oldPathname, newPathname)
+}
+
+func (w *webdavFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	pathname, err := w.pathname("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return w.fs.Stat(pathname)
+}
@@ -0,0 +1,79 @@
+package client_test
+
+import (
+	"context"
+	"github.com/rstms/winexec/wintest"
+	"github.com/stretchr/testify/require"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWebDAV(t *testing.T) {
+	h := wintest.New(t)
+	c := h.Client
+	root := h.Dir
+	davRequest := func(base, method, name string, body io.Reader, headers ...string) (int, string) {
+		r, err := http.NewRequest(method, base+name, body)
+		require.Nil(t, err)
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		response, err := http.DefaultClient.Do(r)
+		require.Nil(t, err)
+		defer response.Body.Close()
+		data, err := io.ReadAll(response.Body)
+		require.Nil(t, err)
+		return response.StatusCode, string(data)
+	}
+
+	server := httptest.NewServer(c.WebDAVHandler(root, false, nil))
+	defer server.Close()
+	status, _ := davRequest(server.URL, "MKCOL", "/dir", nil)
+	require.Equal(t, http.StatusCreated, status)
+	status, _ = davRequest(server.URL, "PUT", "/dir/hello.txt", strings.NewReader("hello, world\n"))
+	require.Equal(t, http.StatusCreated, status)
+	data, err := os.ReadFile(filepath.Join(root, "dir", "hello.txt"))
+	require.Nil(t, err)
+	require.Equal(t, "hello, world\n", string(data))
+	status, body := davRequest(server.URL, "GET", "/dir/hello.txt", nil)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "hello, world\n", body)
+	status, body = davRequest(server.URL, "GET", "/dir/hello.txt", nil, "Range", "bytes=7-11")
+	require.Equal(t, http.StatusPartialContent, status)
+	require.Equal(t, "world", body)
+	status, body = davRequest(server.URL, "PROPFIND", "/dir/", nil, "Depth", "1")
+	require.Equal(t, http.StatusMultiStatus, status)
+	require.Contains(t, body, "/dir/hello.txt")
+	status, _ = davRequest(server.URL, "MOVE", "/dir/hello.txt", nil, "Destination", server.URL+"/moved.txt")
+	require.Equal(t, http.StatusCreated, status)
+	require.FileExists(t, filepath.Join(root, "moved.txt"))
+	status, _ = davRequest(server.URL, "GET", "/dir/hello.txt", nil)
+	require.Equal(t, http.StatusNotFound, status)
+
+	// a read-only gateway allows reads and rejects changes
+	readOnly := httptest.NewServer(c.WebDAVHandler(root, true, nil))
+	defer readOnly.Close()
+	status, body = davRequest(readOnly.URL, "GET", "/moved.txt", nil)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "hello, world\n", body)
+	status, _ = davRequest(readOnly.URL, "PUT", "/new.txt", strings.NewReader("new"))
+	require.Equal(t, http.StatusForbidden, status)
+	status, _ = davRequest(readOnly.URL, "DELETE", "/moved.txt", nil)
+	require.Equal(t, http.StatusForbidden, status)
+	require.FileExists(t, filepath.Join(root, "moved.txt"))
+	require.NoFileExists(t, filepath.Join(root, "new.txt"))
+
+	// the file system rejects changes even when the handler does not filter methods
+	_, err = c.WebDAV(root, true).OpenFile(context.Background(), "/new.txt", os.O_WRONLY|os.O_CREATE, 0644)
+	require.ErrorIs(t, err, fs.ErrPermission)
+
+	status, _ = davRequest(server.URL, "DELETE", "/dir", nil)
+	require.Equal(t, http.StatusNoContent, status)
+	require.NoDirExists(t, filepath.Join(root, "dir"))
+}
@@ -0,0 +1,115 @@
+/*
+Copyright © 2025 Matt Krueger <mkrueger@rstms.net>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+ 1. Redistributions of source code must retain the above copyright notice,
+    this list of conditions and the following disclaimer.
+
+ 2. Redistributions in binary form must reproduce the above copyright notice,
+    this list of conditions and the following disclaimer in the documentation
+    and/or other materials provided with the distribution.
+
+ 3. Neither the name of the copyright holder nor the names of its contributors
+    may be used to endorse or promote products derived from this software
+    without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/rstms/winexec/message"
+	"github.com/spf13/cobra"
+	"strings"
+	"time"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "output recent entries from the winexec server audit log",
+	Long: `
+Output the most recent audit log entries matching the options, oldest
+first.  Times are durations before now, such as 90m, or RFC3339 times.
+--endpoint matches a prefix of the request path, --path and --command
+match substrings, and --failed selects failed requests and commands that
+exited with a nonzero code.
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient()
+		cobra.CheckErr(err)
+		defer c.Close()
+		query := message.AuditQueryRequest{
+			Since:    optionTime("audit.since"),
+			Until:    optionTime("audit.until"),
+			Client:   ViperGetString("audit.client"),
+			Endpoint: ViperGetString("audit.endpoint"),
+			Path:     ViperGetString("audit.path"),
+			Command:  ViperGetString("audit.command"),
+			Failed:   ViperGetBool("audit.failed"),
+			Limit:    ViperGetInt("audit.limit"),
+		}
+		entries, err := c.Audit(&query)
+		cobra.CheckErr(err)
+		lines := []string{}
+		for _, entry := range entries {
+			detail := strings.Join(entry.Paths, " ")
+			if entry.Command != "" {
+				detail = strings.Join(append([]string{entry.Command}, entry.Args...), " ")
+			}
+			line := fmt.Sprintf("%s %s %s %s %d", entry.Time.Local().Format(time.DateTime), entry.Client, entry.RemoteAddr, entry.Endpoint, entry.Status)
+			if entry.ExitCode != nil {
+				line += fmt.Sprintf(" exit=%d", *entry.ExitCode)
+			}
+			if entry.Status >= 400 {
+				line += fmt.Sprintf(" '%s'", entry.Result)
+			}
+			if detail != "" {
+				line += " " + detail
+			}
+			lines = append(lines, line)
+		}
+		output(entries, strings.Join(lines, "\n"))
+	},
+}
+
+// a time option is a duration before now or an RFC3339 time; empty is the zero time
+func optionTime(key string) time.Time {
+	value := ViperGetString(key)
+	if value == "" {
+		return time.Time{}
+	}
+	duration, err := time.ParseDuration(value)
+	if err == nil {
+		return time.Now().Add(-duration)
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	cobra.CheckErr(err)
+	return t
+}
+
+func init() {
+	CobraAddCommand(rootCmd, rootCmd, auditCmd)
+	OptionString(auditCmd, "since", "s", "", "only entries after this time")
+	OptionString(auditCmd, "until", "u", "", "only entries before this time")
+	OptionString(auditCmd, "client", "", "", "client certificate common name")
+	OptionString(auditCmd, "endpoint", "", "", "request path prefix")
+	OptionString(auditCmd, "path", "p", "", "pathname substring")
+	OptionString(auditCmd, "command", "c", "", "command substring")
+	OptionSwitch(auditCmd, "failed", "f", "only failed requests and nonzero exits")
+	OptionInt(auditCmd, "limit", "n", 100, "maximum number of entries")
+}
@@ -0,0 +1,73 @@
+/*
+Copyright © 2025 Matt Krueger <mkrueger@rstms.net>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+ 1. Redistributions of source code must retain the above copyright notice,
+    this list of conditions and the following disclaimer.
+
+ 2. Redistributions in binary form must reproduce the above copyright notice,
+    this list of conditions and the following disclaimer in the documentation
+    and/or other materials provided with the distribution.
+
+ 3. Neither the name of the copyright holder nor the names of its contributors
+    may be used to endorse or promote products derived from this software
+    without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check COMMAND [ARG...]",
+	Short: "test a command against the winexec server command rules",
+	Long: `
+Evaluate a command against the server's command rules without running it.
+The rules are applied as if they were enforced, so they can be tested
+before enforce_command_rules is set.  Output the matching rule and the
+command as it would be run, and exit 0 if it is allowed or 1 if not.
+`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient()
+		cobra.CheckErr(err)
+		result, err := c.CheckCommand(args[0], args[1:], ViperGetStringSlice("check.env"), processOptions(cmd))
+		cobra.CheckErr(err)
+		text := "denied: " + result.Reason
+		if result.Allowed {
+			text = "allowed"
+			if result.Rule != "" {
+				text += fmt.Sprintf(" by rule '%s'", result.Rule)
+			}
+			text += ": " + strings.Join(append([]string{result.Command}, result.Args...), " ")
+			if result.Dir != "" {
+				text += " in " + result.Dir
+			}
+		}
+		output(result, text)
+		exit(c, exitStatus(result.Allowed))
+	},
+}
+
+func init() {
+	CobraAddCommand(rootCmd, rootCmd, checkCmd)
+	addProcessOptions(checkCmd)
+}
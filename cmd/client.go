@@ -0,0 +1,88 @@
+/*
+Copyright © 2025 Matt Krueger <mkrueger@rstms.net>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+ 1. Redistributions of source code must retain the above copyright notice,
+    this list of conditions and the following disclaimer.
+
+ 2. Redistributions in binary form must reproduce the above copyright notice,
+    this list of conditions and the following disclaimer in the documentation
+    and/or other materials provided with the distribution.
+
+ 3. Neither the name of the copyright holder nor the names of its contributors
+    may be used to endorse or promote products derived from this software
+    without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/rstms/winexec/client"
+	"github.com/rstms/winexec/message"
+	"github.com/spf13/cobra"
+	"os"
+	"strconv"
+)
+
+// client subcommands read the server URL and TLS files from the client config section
+func newClient() (*client.WinexecClient, error) {
+	return client.NewWinexecClient(ViperGetString("client.ca"), ViperGetString("client.cert"), ViperGetString("client.key"))
+}
+
+// print result as JSON with the json option, otherwise print text unless quiet
+func output(result any, text string) {
+	if ViperGetBool("json") {
+		fmt.Println(FormatJSON(result))
+		return
+	}
+	if text != "" && !ViperGetBool("quiet") {
+		fmt.Println(text)
+	}
+}
+
+// close the client and exit with code, which may be a remote process exit code
+func exit(c *client.WinexecClient, code int) {
+	err := c.Close()
+	cobra.CheckErr(err)
+	os.Exit(code)
+}
+
+// exec and spawn share the process options
+func addProcessOptions(cobraCmd *cobra.Command) {
+	OptionString(cobraCmd, "dir", "", "", "remote working directory")
+	OptionInt(cobraCmd, "timeout", "t", 0, "timeout in seconds")
+	OptionInt(cobraCmd, "kill-grace", "", 0, "seconds from terminate request to kill on timeout")
+	OptionStringSlice(cobraCmd, "env", "e", []string{}, "remote environment variable NAME=VALUE")
+	// flags after the command name are passed to the remote command
+	cobraCmd.Flags().SetInterspersed(false)
+}
+
+func processOptions(cobraCmd *cobra.Command) *message.ProcessOptions {
+	return &message.ProcessOptions{
+		Dir:              ViperGetString(cobraCmd.Name() + ".dir"),
+		TimeoutSeconds:   ViperGetInt(cobraCmd.Name() + ".timeout"),
+		KillGraceSeconds: ViperGetInt(cobraCmd.Name() + ".kill_grace"),
+	}
+}
+
+// mode options are octal strings
+func optionMode(cobraCmd *cobra.Command, key string) os.FileMode {
+	mode, err := strconv.ParseUint(ViperGetString(cobraCmd.Name()+"."+key), 8, 32)
+	cobra.CheckErr(err)
+	return os.FileMode(mode)
+}
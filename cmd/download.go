@@ -0,0 +1,69 @@
+/*
+Copyright © 2025 Matt Krueger <mkrueger@rstms.net>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+ 1. Redistributions of source code must retain the above copyright notice,
+    this list of conditions and the following disclaimer.
+
+ 2. Redistributions in binary form must reproduce the above copyright notice,
+    this list of conditions and the following disclaimer in the documentation
+    and/or other materials provided with the distribution.
+
+ 3. Neither the name of the copyright holder nor the names of its contributors
+    may be used to endorse or promote products derived from this software
+    without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var downloadCmd = &cobra.Command{
+	Use:   "download REMOTE_PATH LOCAL_PATH",
+	Short: "download a file or directory tree from the winexec server",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient()
+		cobra.CheckErr(err)
+		defer c.Close()
+		src, dst := args[0], args[1]
+		force := ViperGetBool("download.force")
+		isDir, err := c.IsDir(src)
+		cobra.CheckErr(err)
+		if isDir {
+			err = c.DownloadTree(dst, src, force)
+		} else {
+			_, err = os.Stat(dst)
+			if err == nil && !force {
+				err = fmt.Errorf("file exists: %s", dst)
+			} else {
+				err = c.Download(dst, src)
+			}
+		}
+		cobra.CheckErr(err)
+		output(map[string]string{"Source": src, "Destination": dst}, "")
+	},
+}
+
+func init() {
+	CobraAddCommand(rootCmd, rootCmd, downloadCmd)
+	OptionSwitch(downloadCmd, "force", "f", "overwrite existing files")
+}
@@ -0,0 +1,95 @@
+/*
+Copyright © 2025 Matt Krueger <mkrueger@rstms.net>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+ 1. Redistributions of source code must retain the above copyright notice,
+    this list of conditions and the following disclaimer.
+
+ 2. Redistributions in binary form must reproduce the above copyright notice,
+    this list of conditions and the following disclaimer in the documentation
+    and/or other materials provided with the distribution.
+
+ 3. Neither the name of the copyright holder nor the names of its contributors
+    may be used to endorse or promote products derived from this software
+    without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/rstms/winexec/client"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec COMMAND [ARG...]",
+	Short: "execute a command on the winexec server",
+	Long: `
+Execute a command on the winexec server, streaming its stdout and stderr
+as it runs.  With --json, the output is collected and written as a JSON
+object when the command exits.  The exit code is the remote command's, and
+is nonzero when the command is killed on timeout.
+`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient()
+		cobra.CheckErr(err)
+		var stdin io.Reader
+		if ViperGetBool("exec.stdin") {
+			stdin = os.Stdin
+		}
+		var stdout, stderr io.Writer = os.Stdout, os.Stderr
+		var outBuf, errBuf bytes.Buffer
+		if ViperGetBool("json") {
+			stdout, stderr = &outBuf, &errBuf
+		}
+		var exitCode int
+		err = c.ExecStream(args[0], args[1:], ViperGetStringSlice("exec.env"), stdin, stdout, stderr, processOptions(cmd), &exitCode)
+		timedOut := errors.Is(err, client.ErrTimedOut)
+		if !timedOut {
+			cobra.CheckErr(err)
+		}
+		result := map[string]any{
+			"Command":  args[0],
+			"Args":     args[1:],
+			"ExitCode": exitCode,
+			"TimedOut": timedOut,
+			"Stdout":   outBuf.String(),
+			"Stderr":   errBuf.String(),
+		}
+		output(result, "")
+		// a process killed on timeout never exits successfully
+		if timedOut {
+			fmt.Fprintln(os.Stderr, err)
+			if exitCode == 0 {
+				exitCode = 1
+			}
+		}
+		exit(c, exitCode)
+	},
+}
+
+func init() {
+	CobraAddCommand(rootCmd, rootCmd, execCmd)
+	addProcessOptions(execCmd)
+	OptionSwitch(execCmd, "stdin", "i", "send stdin to the remote command")
+}
@@ -0,0 +1,69 @@
+/*
+Copyright © 2025 Matt Krueger <mkrueger@rstms.net>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+ 1. Redistributions of source code must retain the above copyright notice,
+    this list of conditions and the following disclaimer.
+
+ 2. Redistributions in binary form must reproduce the above copyright notice,
+    this list of conditions and the following disclaimer in the documentation
+    and/or other materials provided with the distribution.
+
+ 3. Neither the name of the copyright holder nor the names of its contributors
+    may be used to endorse or promote products derived from this software
+    without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var getCmd = &cobra.Command{
+	Use:   "get URL REMOTE_PATH",
+	Short: "download a URL to a file on the winexec server",
+	Long: `
+Have the winexec server download URL to REMOTE_PATH.  The ca, cert and key
+options name local PEM files sent to the server for the download's TLS
+connection.  With sha256, the file is kept only if its digest matches.
+`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient()
+		cobra.CheckErr(err)
+		defer c.Close()
+		url, dst := args[0], args[1]
+		var autoDelete *int
+		if cmd.Flags().Changed("auto-delete") {
+			seconds := ViperGetInt("get.auto_delete")
+			autoDelete = &seconds
+		}
+		err = c.GetISOVerified(dst, url, ViperGetString("get.ca"), ViperGetString("get.cert"), ViperGetString("get.key"), ViperGetString("get.sha256"), autoDelete)
+		cobra.CheckErr(err)
+		output(map[string]string{"URL": url, "Pathname": dst}, "")
+	},
+}
+
+func init() {
+	CobraAddCommand(rootCmd, rootCmd, getCmd)
+	OptionString(getCmd, "ca", "", "", "certificate authority PEM file")
+	OptionString(getCmd, "cert", "", "", "client certificate PEM file")
+	OptionString(getCmd, "key", "", "", "client certificate key PEM file")
+	OptionString(getCmd, "sha256", "", "", "expected SHA256 digest")
+	OptionInt(getCmd, "auto-delete", "", 0, "seconds until the server deletes the file")
+}
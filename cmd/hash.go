@@ -0,0 +1,72 @@
+/*
+Copyright © 2025 Matt Krueger <mkrueger@rstms.net>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+ 1. Redistributions of source code must retain the above copyright notice,
+    this list of conditions and the following disclaimer.
+
+ 2. Redistributions in binary form must reproduce the above copyright notice,
+    this list of conditions and the following disclaimer in the documentation
+    and/or other materials provided with the distribution.
+
+ 3. Neither the name of the copyright holder nor the names of its contributors
+    may be used to endorse or promote products derived from this software
+    without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/rstms/winexec/checksum"
+	"github.com/spf13/cobra"
+	"slices"
+	"strings"
+)
+
+var hashCmd = &cobra.Command{
+	Use:   "hash REMOTE_PATH",
+	Short: "output digests of a file or directory tree on the winexec server",
+	Long: `
+Output a digest and relative pathname for each file, in the format of
+sha256sum.  Algorithms are md5, sha1, sha256 and sha512.
+`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient()
+		cobra.CheckErr(err)
+		defer c.Close()
+		algorithm := ViperGetString("hash.algorithm")
+		files, err := c.Hash(args[0], algorithm)
+		cobra.CheckErr(err)
+		names := []string{}
+		for name := range files {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		lines := []string{}
+		for _, name := range names {
+			lines = append(lines, fmt.Sprintf("%s  %s", files[name].Sums[algorithm], name))
+		}
+		output(files, strings.Join(lines, "\n"))
+	},
+}
+
+func init() {
+	CobraAddCommand(rootCmd, rootCmd, hashCmd)
+	OptionString(hashCmd, "algorithm", "a", checksum.SHA256, "digest algorithm")
+}
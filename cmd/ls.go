@@ -0,0 +1,74 @@
+/*
+Copyright © 2025 Matt Krueger <mkrueger@rstms.net>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+ 1. Redistributions of source code must retain the above copyright notice,
+    this list of conditions and the following disclaimer.
+
+ 2. Redistributions in binary form must reproduce the above copyright notice,
+    this list of conditions and the following disclaimer in the documentation
+    and/or other materials provided with the distribution.
+
+ 3. Neither the name of the copyright holder nor the names of its contributors
+    may be used to endorse or promote products derived from this software
+    without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"slices"
+	"strings"
+	"time"
+)
+
+var lsCmd = &cobra.Command{
+	Use:   "ls REMOTE_DIR",
+	Short: "list a directory on the winexec server",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient()
+		cobra.CheckErr(err)
+		defer c.Close()
+		entries, err := c.DirEntries(args[0])
+		cobra.CheckErr(err)
+		names := []string{}
+		for name := range entries {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		lines := []string{}
+		for _, name := range names {
+			entry := entries[name]
+			if entry.Mode.IsDir() {
+				name += "/"
+			}
+			if ViperGetBool("ls.long") {
+				name = fmt.Sprintf("%s %12d %s %s", entry.Mode, entry.Size, entry.ModTime.Local().Format(time.DateTime), name)
+			}
+			lines = append(lines, name)
+		}
+		output(entries, strings.Join(lines, "\n"))
+	},
+}
+
+func init() {
+	CobraAddCommand(rootCmd, rootCmd, lsCmd)
+	OptionSwitch(lsCmd, "long", "l", "output mode, size and modification time")
+}
@@ -60,5 +60,6 @@ func Execute() {
 func init() {
 	CobraInit(rootCmd)
 	OptionSwitch(rootCmd, "quiet", "q", "suppress output")
+	OptionSwitch(rootCmd, "json", "j", "format client command output as JSON")
 	daemon.AddDaemonCommands(rootCmd, "server")
 }
@@ -48,6 +48,10 @@ var serverCmd = &cobra.Command{
 	Long: `
 Implement an HTTPS API server for remote execution and filesystem functions, 
 secured by mutual TLS authentication with a private x509 CA
+
+Request auditing is off by default.  Set the server.audit_log key to a file
+path to write an entry for each request to that file, rotated by
+server.audit_max_bytes and server.audit_max_files.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		daemon, err := server.NewWinexecServer()
@@ -0,0 +1,100 @@
+/*
+Copyright © 2025 Matt Krueger <mkrueger@rstms.net>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+ 1. Redistributions of source code must retain the above copyright notice,
+    this list of conditions and the following disclaimer.
+
+ 2. Redistributions in binary form must reproduce the above copyright notice,
+    this list of conditions and the following disclaimer in the documentation
+    and/or other materials provided with the distribution.
+
+ 3. Neither the name of the copyright holder nor the names of its contributors
+    may be used to endorse or promote products derived from this software
+    without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"github.com/rstms/winexec/client"
+	"github.com/rstms/winexec/message"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"os"
+)
+
+var shellCmd = &cobra.Command{
+	Use:   "shell [COMMAND [ARG...]]",
+	Short: "run an interactive shell on the winexec server",
+	Long: `
+Run an interactive session on the winexec server, forwarding stdin and
+output until the remote process exits.  The default command is the
+server's shell: $SHELL or /bin/sh on unix, %COMSPEC% on windows.
+
+When stdin is a terminal and the server supports it, the remote process
+is attached to a pseudo-terminal sized to the local window, the local
+terminal is placed in raw mode, and window size changes are forwarded.
+Windows servers run the session with pipes, so input is line buffered
+and echoed locally.  Use --no-tty to force pipes.  The exit code is the
+remote command's.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient()
+		cobra.CheckErr(err)
+		request := message.ShellRequest{
+			Env:            ViperGetStringSlice("shell.env"),
+			ProcessOptions: *processOptions(cmd),
+		}
+		if len(args) > 0 {
+			request.Command = args[0]
+			request.Args = args[1:]
+		}
+		var resize <-chan client.WindowSize
+		var state *term.State
+		stdinFd := int(os.Stdin.Fd())
+		if !ViperGetBool("shell.no_tty") && term.IsTerminal(stdinFd) {
+			remoteOS, err := c.GetOS()
+			cobra.CheckErr(err)
+			if remoteOS != "windows" {
+				request.Term = os.Getenv("TERM")
+				if request.Term == "" {
+					request.Term = "xterm"
+				}
+				request.Cols, request.Rows, err = term.GetSize(int(os.Stdout.Fd()))
+				cobra.CheckErr(err)
+				state, err = term.MakeRaw(stdinFd)
+				cobra.CheckErr(err)
+				resize = watchWindowSize(int(os.Stdout.Fd()))
+			}
+		}
+		var exitCode int
+		err = c.Shell(&request, os.Stdin, os.Stdout, os.Stderr, resize, &exitCode)
+		// the terminal is restored before exit, since deferred calls are skipped
+		if state != nil {
+			term.Restore(stdinFd, state)
+		}
+		cobra.CheckErr(err)
+		exit(c, exitCode)
+	},
+}
+
+func init() {
+	CobraAddCommand(rootCmd, rootCmd, shellCmd)
+	addProcessOptions(shellCmd)
+	OptionSwitch(shellCmd, "no-tty", "T", "do not request a pseudo-terminal")
+}
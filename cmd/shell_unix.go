@@ -0,0 +1,27 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"github.com/rstms/winexec/client"
+	"golang.org/x/term"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// send the terminal size on each SIGWINCH
+func watchWindowSize(fd int) <-chan client.WindowSize {
+	sizes := make(chan client.WindowSize)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGWINCH)
+	go func() {
+		for range signals {
+			cols, rows, err := term.GetSize(fd)
+			if err == nil {
+				sizes <- client.WindowSize{Rows: rows, Cols: cols}
+			}
+		}
+	}()
+	return sizes
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package cmd
+
+import (
+	"github.com/rstms/winexec/client"
+	"golang.org/x/term"
+	"time"
+)
+
+const WINDOW_SIZE_POLL_INTERVAL = 500 * time.Millisecond
+
+// the console has no resize signal, so its size is polled
+func watchWindowSize(fd int) <-chan client.WindowSize {
+	sizes := make(chan client.WindowSize)
+	go func() {
+		lastCols, lastRows, _ := term.GetSize(fd)
+		for range time.Tick(WINDOW_SIZE_POLL_INTERVAL) {
+			cols, rows, err := term.GetSize(fd)
+			if err == nil && (cols != lastCols || rows != lastRows) {
+				lastCols, lastRows = cols, rows
+				sizes <- client.WindowSize{Rows: rows, Cols: cols}
+			}
+		}
+	}()
+	return sizes
+}
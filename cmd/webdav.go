@@ -0,0 +1,94 @@
+/*
+Copyright © 2025 Matt Krueger <mkrueger@rstms.net>
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+ 1. Redistributions of source code must retain the above copyright notice,
+    this list of conditions and the following disclaimer.
+
+ 2. Redistributions in binary form must reproduce the above copyright notice,
+    this list of conditions and the following disclaimer in the documentation
+    and/or other materials provided with the distribution.
+
+ 3. Neither the name of the copyright holder nor the names of its contributors
+    may be used to endorse or promote products derived from this software
+    without specific prior written permission.
+
+THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS IS"
+AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO, THE
+IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR PURPOSE
+ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR CONTRIBUTORS BE
+LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL, EXEMPLARY, OR
+CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO, PROCUREMENT OF
+SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR PROFITS; OR BUSINESS
+INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF LIABILITY, WHETHER IN
+CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING NEGLIGENCE OR OTHERWISE)
+ARISING IN ANY WAY OUT OF THE USE OF THIS SOFTWARE, EVEN IF ADVISED OF THE
+POSSIBILITY OF SUCH DAMAGE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"log"
+	"net"
+	"net/http"
+)
+
+var webdavCmd = &cobra.Command{
+	Use:   "webdav",
+	Short: "serve a remote winexec filesystem over WebDAV",
+	Long: `
+Run a local WebDAV server proxying every operation to the winexec server
+configured in the client section, so the remote filesystem can be browsed
+and updated with standard tools.  The WebDAV server speaks plain HTTP
+without authentication, so anyone who can connect has the client's access
+to the remote filesystem.  It listens on the loopback address, and a
+non-loopback bind-address is refused unless --allow-remote is also given.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		c, err := newClient()
+		cobra.CheckErr(err)
+		defer c.Close()
+		logger := func(r *http.Request, err error) {
+			if err != nil {
+				log.Printf("webdav %s %s: %v\n", r.Method, r.URL.Path, err)
+			} else if ViperGetBool("verbose") {
+				log.Printf("webdav %s %s\n", r.Method, r.URL.Path)
+			}
+		}
+		bindAddress := ViperGetString("webdav.bind_address")
+		if !isLoopback(bindAddress) {
+			if !ViperGetBool("webdav.allow_remote") {
+				cobra.CheckErr(fmt.Errorf("refusing to serve unauthenticated WebDAV on '%s' without --allow-remote", bindAddress))
+			}
+			Warning("serving unauthenticated WebDAV on '%s'", bindAddress)
+		}
+		handler := c.WebDAVHandler(ViperGetString("webdav.root"), ViperGetBool("webdav.read_only"), logger)
+		addr := net.JoinHostPort(bindAddress, ViperGetString("webdav.port"))
+		log.Printf("webdav listening on %s\n", addr)
+		err = http.ListenAndServe(addr, handler)
+		cobra.CheckErr(err)
+	},
+}
+
+func init() {
+	CobraAddCommand(rootCmd, rootCmd, webdavCmd)
+	OptionString(webdavCmd, "bind-address", "a", "127.0.0.1", "bind address")
+	OptionString(webdavCmd, "port", "p", "8080", "listen port")
+	OptionString(webdavCmd, "root", "r", "/", "remote directory served as the WebDAV root")
+	OptionSwitch(webdavCmd, "read-only", "", "reject requests that change the remote filesystem")
+	OptionSwitch(webdavCmd, "allow-remote", "", "allow a bind address other than loopback")
+}
+
+// an empty address binds every interface
+func isLoopback(address string) bool {
+	if address == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(address)
+	return ip != nil && ip.IsLoopback()
+}
@@ -3,17 +3,21 @@ package geturl
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"github.com/rstms/winexec/checksum"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 )
 
-func GetURL(dstPathname, srcURL string, ca, cert, key []byte) (int64, error) {
+// download srcURL to a temporary file beside dstPathname, renaming it into place only
+// after any expected digest matches; the returned Hasher holds the computed digests
+func GetURL(dstPathname, srcURL string, ca, cert, key []byte, sha256, sha512 string) (int64, *checksum.Hasher, error) {
 	client := http.Client{}
 	parsedURL, err := url.Parse(srcURL)
 	if err != nil {
-		return 0, Fatal(err)
+		return 0, nil, Fatal(err)
 	}
 	if parsedURL.Scheme == "https" {
 		var caCertPool *x509.CertPool
@@ -21,12 +25,12 @@ func GetURL(dstPathname, srcURL string, ca, cert, key []byte) (int64, error) {
 			caCertPool = x509.NewCertPool()
 			ok := caCertPool.AppendCertsFromPEM(ca)
 			if !ok {
-				return 0, Fatalf("failed appending ca to cert pool")
+				return 0, nil, Fatalf("failed appending ca to cert pool")
 			}
 		} else {
 			caCertPool, err = x509.SystemCertPool()
 			if err != nil {
-				return 0, Fatalf("failed reading SystemCertPool: %v", err)
+				return 0, nil, Fatalf("failed reading SystemCertPool: %v", err)
 			}
 		}
 
@@ -39,7 +43,7 @@ func GetURL(dstPathname, srcURL string, ca, cert, key []byte) (int64, error) {
 		if len(cert) > 0 && len(key) > 0 {
 			clientCert, err := tls.X509KeyPair(cert, key)
 			if err != nil {
-				return 0, Fatal(err)
+				return 0, nil, Fatal(err)
 			}
 			transport.TLSClientConfig.Certificates = []tls.Certificate{clientCert}
 
@@ -50,17 +54,44 @@ func GetURL(dstPathname, srcURL string, ca, cert, key []byte) (int64, error) {
 
 	response, err := client.Get(parsedURL.String())
 	if err != nil {
-		return 0, Fatal(err)
+		return 0, nil, Fatal(err)
 	}
 	defer response.Body.Close()
-	ofp, err := os.Create(dstPathname)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return 0, nil, Fatalf("GET %s failed: %s", parsedURL.Redacted(), response.Status)
+	}
+
+	dir, name := filepath.Split(dstPathname)
+	if dir == "" {
+		dir = "."
+	}
+	ofp, err := os.CreateTemp(dir, "."+name+".winexec-*")
+	if err != nil {
+		return 0, nil, Fatal(err)
+	}
+	tempPathname := ofp.Name()
+	defer os.Remove(tempPathname)
+	hasher, err := checksum.New()
+	if err != nil {
+		ofp.Close()
+		return 0, nil, Fatal(err)
+	}
+	count, err := io.Copy(io.MultiWriter(ofp, hasher), response.Body)
+	if err != nil {
+		ofp.Close()
+		return 0, nil, Fatal(err)
+	}
+	err = ofp.Close()
+	if err != nil {
+		return 0, nil, Fatal(err)
+	}
+	err = hasher.VerifyAll(sha256, sha512)
 	if err != nil {
-		return 0, Fatal(err)
+		return 0, hasher, Fatal(err)
 	}
-	defer ofp.Close()
-	count, err := io.Copy(ofp, response.Body)
+	err = os.Rename(tempPathname, dstPathname)
 	if err != nil {
-		return 0, Fatal(err)
+		return 0, nil, Fatal(err)
 	}
-	return count, nil
+	return count, hasher, nil
 }
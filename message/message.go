@@ -5,6 +5,9 @@ import (
 	"time"
 )
 
+// a request for a path outside the server's sandbox roots fails with this status
+const STATUS_PATH_REJECTED = 460
+
 type FailResponse struct {
 	Success bool
 	Message string
@@ -15,10 +18,21 @@ type SuccessResponse struct {
 	Message string
 }
 
+// ProcessOptions set the working directory and time limit for a command;
+// on timeout the process tree is sent a terminate request, then killed
+// after KillGraceSeconds
+type ProcessOptions struct {
+	Dir              string
+	TimeoutSeconds   int
+	KillGraceSeconds int
+}
+
 type ExecRequest struct {
 	Command string
 	Args    []string
 	Env     []string
+	Stdin   []byte
+	ProcessOptions
 }
 
 type ExecResponse struct {
@@ -26,21 +40,134 @@ type ExecResponse struct {
 	Message  string
 	Command  string
 	ExitCode int
+	TimedOut bool
 	Stdout   string
 	Stderr   string
 }
 
-type SpawnRequest struct {
+const EXEC_STDOUT = "stdout"
+const EXEC_STDERR = "stderr"
+const EXEC_EXIT = "exit"
+const EXEC_ERROR = "error"
+
+type ExecFrame struct {
+	Stream   string
+	Data     []byte
+	ExitCode int
+	TimedOut bool
+	Message  string
+}
+
+// a shell session is started with a ShellRequest line; when Term is set the
+// process is attached to a pseudo-terminal of the given size if the server
+// supports one, otherwise stdin, stdout and stderr are pipes
+type ShellRequest struct {
 	Command string
 	Args    []string
 	Env     []string
+	Term    string
+	Rows    int
+	Cols    int
+	ProcessOptions
 }
 
-type SpawnResponse struct {
+const SHELL_STDIN = "stdin"
+const SHELL_RESIZE = "resize"
+
+// the request body continues with ShellFrame lines carrying input and window
+// size changes; output is returned as ExecFrame lines
+type ShellFrame struct {
+	Stream string
+	Data   []byte
+	Rows   int
+	Cols   int
+}
+
+// a dry run evaluates a command against the server's command rules without running
+// it; the response has the matching rule and the command as it would be run
+type CommandCheckRequest struct {
+	Command string
+	Args    []string
+	Env     []string
+	ProcessOptions
+}
+
+type CommandCheckResponse struct {
 	Success  bool
 	Message  string
+	Allowed  bool
+	Enforced bool
+	Rule     string
+	Reason   string
 	Command  string
-	ExitCode int
+	Args     []string
+	Env      []string
+	Dir      string
+}
+
+type SpawnRequest struct {
+	Command string
+	Args    []string
+	Env     []string
+	ProcessOptions
+}
+
+type SpawnResponse struct {
+	Success bool
+	Message string
+	Command string
+	JobID   string
+	PID     int
+}
+
+type JobStatus struct {
+	ID        string
+	PID       int
+	Command   string
+	Spawned   bool
+	Running   bool
+	ExitCode  int
+	TimedOut  bool
+	Killed    bool
+	Error     string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+type JobRequest struct {
+	ID             string
+	TimeoutSeconds int
+}
+
+type JobResponse struct {
+	Success bool
+	Message string
+	Job     JobStatus
+}
+
+type JobListResponse struct {
+	Success bool
+	Message string
+	Jobs    []JobStatus
+}
+
+type JobOutputRequest struct {
+	ID           string
+	StdoutOffset int64
+	StderrOffset int64
+}
+
+// output is returned as bytes, so binary output is not altered
+type JobOutputResponse struct {
+	Success      bool
+	Message      string
+	ID           string
+	Running      bool
+	Stdout       []byte
+	Stderr       []byte
+	StdoutOffset int64
+	StderrOffset int64
+	Truncated    bool
 }
 
 type FileGetRequest struct {
@@ -50,6 +177,8 @@ type FileGetRequest struct {
 	Cert              []byte
 	Key               []byte
 	AutoDeleteSeconds int
+	SHA256            string
+	SHA512            string
 }
 
 type FileGetResponse struct {
@@ -57,10 +186,15 @@ type FileGetResponse struct {
 	Message  string
 	Pathname string
 	Bytes    int64
+	SHA256   string
+	SHA512   string
 }
 
+// Offset and Length select a byte range; a zero Length reads to the end of the file
 type FileDownloadRequest struct {
 	Pathname string
+	Offset   int64
+	Length   int64
 }
 
 type FileDownloadResponse struct {
@@ -70,20 +204,134 @@ type FileDownloadResponse struct {
 	Content   []byte
 	Timestamp time.Time
 	Mode      fs.FileMode
+	SHA256    string
+	SHA512    string
 }
 
+// when SHA256 or SHA512 is set, the upload is rejected unless the written content matches
 type FileUploadRequest struct {
 	Pathname  string
 	Content   []byte
 	Timestamp time.Time
 	Mode      fs.FileMode
 	Force     bool
+	SHA256    string
+	SHA512    string
+}
+
+type UploadSessionRequest struct {
+	Pathname  string
+	Size      int64
+	Timestamp time.Time
+	Mode      fs.FileMode
+	Force     bool
+	SHA256    string
+	SHA512    string
+}
+
+// chunk, status, commit and abort requests refer to a session created by UploadSessionRequest;
+// a chunk request line is followed by the chunk data, which is written at Offset
+type UploadRequest struct {
+	SessionID string
+	Offset    int64
+}
+
+// Offset is the count of bytes confirmed written to the session's temporary file
+type UploadSessionResponse struct {
+	Success   bool
+	Message   string
+	SessionID string
+	Pathname  string
+	Size      int64
+	Offset    int64
+	SHA256    string
+	SHA512    string
 }
 
 type FileResponse struct {
 	Success  bool
 	Message  string
 	Pathname string
+	Bytes    int64
+	SHA256   string
+	SHA512   string
+}
+
+// streamed downloads return file metadata in these response headers
+const HEADER_PATHNAME = "X-Winexec-Pathname"
+const HEADER_SIZE = "X-Winexec-Size"
+const HEADER_OFFSET = "X-Winexec-Offset"
+const HEADER_LENGTH = "X-Winexec-Length"
+const HEADER_MODE = "X-Winexec-Mode"
+const HEADER_TIMESTAMP = "X-Winexec-Timestamp"
+
+// digests of the bytes sent are returned in response trailers
+const HEADER_SHA256 = "X-Winexec-Sha256"
+const HEADER_SHA512 = "X-Winexec-Sha512"
+
+// Overwrite allows an existing destination file to be replaced; an existing
+// directory is never replaced by a rename
+type FileRenameRequest struct {
+	Source      string
+	Destination string
+	Overwrite   bool
+}
+
+// Recursive is required to copy a directory; with Overwrite, a directory is copied
+// into an existing destination directory, replacing files that exist in both
+type FileCopyRequest struct {
+	Source      string
+	Destination string
+	Overwrite   bool
+	Recursive   bool
+}
+
+// open a file as os.OpenFile does and return its information, following symlinks;
+// no handle is kept open, so writes are made with separate FileWriteRequests
+type FileOpenRequest struct {
+	Pathname  string
+	Read      bool
+	Write     bool
+	Create    bool
+	Exclusive bool
+	Truncate  bool
+	Mode      fs.FileMode
+}
+
+// the request line is followed by the data, which is written to the existing file at Offset
+type FileWriteRequest struct {
+	Pathname string
+	Offset   int64
+}
+
+type FileTruncateRequest struct {
+	Pathname string
+	Size     int64
+}
+
+type FileChmodRequest struct {
+	Pathname string
+	Mode     fs.FileMode
+}
+
+// ownership can only be changed on unix servers
+type FileChownRequest struct {
+	Pathname string
+	UID      int
+	GID      int
+}
+
+type FileChtimesRequest struct {
+	Pathname   string
+	AccessTime time.Time
+	ModTime    time.Time
+}
+
+// remove a file or empty directory, or with Recursive, a directory and its contents;
+// a missing path is an error unless Recursive is set, as with os.Remove and os.RemoveAll
+type FileRemoveRequest struct {
+	Pathname  string
+	Recursive bool
 }
 
 type FileDeleteRequest struct {
@@ -94,20 +342,77 @@ type DirectoryRequest struct {
 	Pathname string
 }
 
+// NoParents creates only the final element, failing if the parent is missing or
+// the directory exists, as os.Mkdir does
 type DirectoryCreateRequest struct {
-	Pathname string
-	Mode     fs.FileMode
+	Pathname  string
+	Mode      fs.FileMode
+	NoParents bool
 }
 
 type DirectoryDestroyRequest struct {
 	Pathname string
 }
 
-type DirectoryEntry struct {
-	Name    string
-	Size    int64
-	ModTime time.Time
-	Mode    fs.FileMode
+// times other than ModTime are zero where the server platform does not provide them;
+// ChangeTime is the unix inode change time and CreateTime the windows creation time
+type FileStat struct {
+	Name       string
+	Size       int64
+	ModTime    time.Time
+	Mode       fs.FileMode
+	AccessTime time.Time
+	ChangeTime time.Time
+	CreateTime time.Time
+	Owner      string
+	Group      string
+	LinkTarget string
+	ReadOnly   bool
+	Hidden     bool
+}
+
+type DirectoryEntry = FileStat
+
+// patterns use path.Match syntax and are tested against both the slash separated
+// pathname relative to the walk root and the base name; Include and the size and
+// time filters select non-directory entries, while an excluded directory is not
+// descended; zero values do not filter, and MaxDepth 1 lists only the root's entries
+type WalkOptions struct {
+	MaxDepth       int
+	Include        []string
+	Exclude        []string
+	MinSize        int64
+	MaxSize        int64
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+}
+
+type WalkRequest struct {
+	Pathname string
+	WalkOptions
+}
+
+// the walk response is a stream of NDJSON frames, the root first with Path ".";
+// Error reports a failure reading the entry's directory, and the final frame has
+// Done set, or Error set with no Path if the walk failed
+type WalkFrame struct {
+	Path  string
+	Stat  FileStat
+	Error string
+	Done  bool
+}
+
+// a symlink is described itself unless Follow is set, which describes its target
+type StatRequest struct {
+	Pathname string
+	Follow   bool
+}
+
+type StatResponse struct {
+	Success  bool
+	Message  string
+	Pathname string
+	Stat     FileStat
 }
 
 type DirectoryResponse struct {
@@ -117,6 +422,94 @@ type DirectoryResponse struct {
 	Entries  map[string]DirectoryEntry
 }
 
+// Format is tar, tar.gz or zip; the request line is followed by the archive data,
+// which is extracted under Pathname
+type ArchiveExtractRequest struct {
+	Pathname string
+	Format   string
+	Force    bool
+}
+
+// the response body is the archive of the directory Pathname
+type ArchivePackRequest struct {
+	Pathname string
+	Format   string
+}
+
+type ArchiveResponse struct {
+	Success     bool
+	Message     string
+	Pathname    string
+	Files       int
+	Directories int
+	Skipped     int
+	Bytes       int64
+}
+
+// Algorithms defaults to sha256; md5 and sha1 are also supported
+type HashRequest struct {
+	Pathname   string
+	Algorithms []string
+}
+
+// Sums maps algorithm names to hex digests
+type FileHash struct {
+	Size    int64
+	ModTime time.Time
+	Sums    map[string]string
+}
+
+// Files is keyed by slash separated pathname relative to a requested directory,
+// or by the file name when a single file is requested
+type HashResponse struct {
+	Success  bool
+	Message  string
+	Pathname string
+	Files    map[string]FileHash
+}
+
+// an audit entry is written for each request; Paths are the request pathnames as
+// sent by the client, ExitCode is set for processes run to completion by the request,
+// and Client and Fingerprint identify the client certificate
+type AuditEntry struct {
+	Time            time.Time
+	Client          string
+	Fingerprint     string
+	Policy          string
+	RemoteAddr      string
+	Method          string
+	Endpoint        string
+	Paths           []string
+	Command         string
+	Args            []string
+	Status          int
+	Result          string
+	ExitCode        *int
+	BytesIn         int64
+	BytesOut        int64
+	DurationSeconds float64
+}
+
+// zero values do not filter; Endpoint matches a prefix, Path and Command match
+// substrings, and Failed selects failed requests and nonzero exit codes; the most
+// recent Limit entries are returned, 100 by default
+type AuditQueryRequest struct {
+	Since    time.Time
+	Until    time.Time
+	Client   string
+	Endpoint string
+	Path     string
+	Command  string
+	Failed   bool
+	Limit    int
+}
+
+type AuditQueryResponse struct {
+	Success bool
+	Message string
+	Entries []AuditEntry
+}
+
 type GetOSResponse struct {
 	Success bool
 	Message string
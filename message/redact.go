@@ -0,0 +1,163 @@
+package message
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const REDACTED = "[REDACTED]"
+
+// strings longer than this are summarized in logs
+const LOG_STRING_LIMIT = 256
+
+var secretName = regexp.MustCompile(`(?i)(^key$|passw(or)?d|secret|token|api_?key|private_?key|credential|authorization)`)
+
+// IsSecretName reports whether a field, variable or option name suggests a secret value
+func IsSecretName(name string) bool {
+	return secretName.MatchString(strings.TrimLeft(name, "-"))
+}
+
+// RedactURL replaces the password in a URL; other strings are returned unchanged
+func RedactURL(value string) string {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.User == nil {
+		return value
+	}
+	_, ok := parsed.User.Password()
+	if !ok {
+		return value
+	}
+	parsed.User = url.UserPassword(parsed.User.Username(), REDACTED)
+	return parsed.String()
+}
+
+// RedactEnv replaces the values of environment variables with secret names
+func RedactEnv(env []string) []string {
+	if env == nil {
+		return nil
+	}
+	redacted := make([]string, len(env))
+	for i, variable := range env {
+		name, _, found := strings.Cut(variable, "=")
+		if found && IsSecretName(name) {
+			variable = name + "=" + REDACTED
+		}
+		redacted[i] = variable
+	}
+	return redacted
+}
+
+// Redacted formats a value for logging as the %+v verb does, replacing fields with
+// secret names, the values of secret environment variables and URL passwords, and
+// summarizing byte slices and long strings by length and SHA-256 digest
+func Redacted(v any) string {
+	var b strings.Builder
+	writeRedacted(&b, reflect.ValueOf(v), "", true)
+	return b.String()
+}
+
+func summarize(data []byte) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("[%d bytes sha256:%s]", len(data), hex.EncodeToString(sum[:8]))
+}
+
+var stringerType = reflect.TypeFor[fmt.Stringer]()
+
+func writeRedacted(b *strings.Builder, v reflect.Value, name string, top bool) {
+	if !v.IsValid() {
+		b.WriteString("<nil>")
+		return
+	}
+	if name != "" && IsSecretName(name) && !v.IsZero() {
+		b.WriteString(REDACTED)
+		return
+	}
+	if !v.CanInterface() {
+		fmt.Fprintf(b, "%v", v)
+		return
+	}
+	if v.Type().Implements(stringerType) && v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface {
+		b.WriteString(v.Interface().(fmt.Stringer).String())
+		return
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			b.WriteString("<nil>")
+			return
+		}
+		if top && v.Elem().Kind() == reflect.Struct {
+			b.WriteString("&")
+		}
+		writeRedacted(b, v.Elem(), "", top)
+	case reflect.Interface:
+		writeRedacted(b, v.Elem(), "", false)
+	case reflect.String:
+		value := v.String()
+		if len(value) > LOG_STRING_LIMIT {
+			value = summarize([]byte(value))
+		}
+		b.WriteString(RedactURL(value))
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.Kind() == reflect.Slice && v.IsNil() {
+				b.WriteString("[]")
+				return
+			}
+			data := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(data), v)
+			b.WriteString(summarize(data))
+			return
+		}
+		if env, ok := v.Interface().([]string); ok && name == "Env" {
+			v = reflect.ValueOf(RedactEnv(env))
+		}
+		b.WriteString("[")
+		for i := range v.Len() {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			writeRedacted(b, v.Index(i), "", false)
+		}
+		b.WriteString("]")
+	case reflect.Map:
+		if v.IsNil() {
+			b.WriteString("map[]")
+			return
+		}
+		keys := v.MapKeys()
+		slices.SortFunc(keys, func(x, y reflect.Value) int {
+			return strings.Compare(fmt.Sprint(x.Interface()), fmt.Sprint(y.Interface()))
+		})
+		b.WriteString("map[")
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			writeRedacted(b, key, "", false)
+			b.WriteString(":")
+			writeRedacted(b, v.MapIndex(key), "", false)
+		}
+		b.WriteString("]")
+	case reflect.Struct:
+		b.WriteString("{")
+		t := v.Type()
+		for i := range t.NumField() {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			field := t.Field(i)
+			b.WriteString(field.Name + ":")
+			writeRedacted(b, v.Field(i), field.Name, false)
+		}
+		b.WriteString("}")
+	default:
+		fmt.Fprintf(b, "%v", v.Interface())
+	}
+}
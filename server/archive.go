@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/rstms/winexec/archive"
+	"github.com/rstms/winexec/message"
+	"log"
+	"net/http"
+)
+
+// the request body is an ArchiveExtractRequest line followed by the archive data
+func (s *WinexecServer) handleArchiveExtract(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.ArchiveExtractRequest
+	body, err := decodePreamble(r, &request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	if IsFile(pathname) {
+		Warning("file exists: '%s'", pathname)
+		fail(w, r, "file exists", http.StatusBadRequest)
+		return
+	}
+	options := archive.Options{
+		Limits: archive.Limits{MaxBytes: s.archiveMaxBytes, MaxFiles: s.archiveMaxFiles},
+		Force:  request.Force,
+	}
+	stats, err := archive.Extract(body, pathname, request.Format, options)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		switch {
+		case errors.Is(err, archive.ErrLimit):
+			fail(w, r, "archive limit exceeded", http.StatusRequestEntityTooLarge)
+		case errors.Is(err, archive.ErrUnsafePath):
+			fail(w, r, "unsafe archive path", http.StatusBadRequest)
+		case errors.Is(err, archive.ErrExists):
+			fail(w, r, "file exists", http.StatusBadRequest)
+		default:
+			fail(w, r, "extract failed", http.StatusBadRequest)
+		}
+		return
+	}
+	response := message.ArchiveResponse{
+		Success:     true,
+		Message:     "extracted",
+		Pathname:    pathname,
+		Files:       stats.Files,
+		Directories: stats.Directories,
+		Skipped:     stats.Skipped,
+		Bytes:       stats.Bytes,
+	}
+	succeed(w, r, &response)
+}
+
+// the response body is the archive; a failure after the header is sent aborts the
+// connection so the client cannot mistake a truncated archive for a complete one
+func handleArchivePack(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.ArchivePackRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	format, err := archive.Format(request.Format)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "unsupported format", http.StatusBadRequest)
+		return
+	}
+	pathname, err := readPath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	if failIfNotDir(pathname, w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", archive.ContentType(format))
+	w.Header().Set(message.HEADER_PATHNAME, pathname)
+	w.WriteHeader(http.StatusOK)
+	stats, err := archive.Pack(w, pathname, format)
+	if err != nil {
+		Warning("archive pack failed: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+	if Verbose {
+		log.Printf("%s <- winexec archive [200] %s %s\n", r.RemoteAddr, pathname, message.Redacted(*stats))
+	}
+}
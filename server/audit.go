@@ -0,0 +1,425 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/rstms/winexec/message"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DEFAULT_AUDIT_MAX_BYTES = 10 * 1024 * 1024
+const DEFAULT_AUDIT_MAX_FILES = 5
+const DEFAULT_AUDIT_QUERY_LIMIT = 100
+
+// the result recorded for a request whose handler panicked, such as with http.ErrAbortHandler
+const AUDIT_ABORTED = "aborted"
+
+// the audit log is written as JSON lines; when maxBytes is set, the file is renamed
+// with a numeric suffix as it reaches that size and maxFiles renamed files are kept,
+// and a file renamed or removed by an external rotation is reopened on the next write
+type auditLog struct {
+	mutex    sync.Mutex
+	pathname string
+	maxBytes int64
+	maxFiles int
+	redact   []*regexp.Regexp
+	file     *os.File
+	size     int64
+}
+
+type auditRecord struct {
+	mutex sync.Mutex
+	entry message.AuditEntry
+}
+
+type auditKey struct{}
+
+// patterns are checked by NewWinexecServer, so an invalid pattern here is skipped
+func newAuditLog(pathname string, maxBytes int64, maxFiles int, redact []string) *auditLog {
+	if pathname == "" {
+		return nil
+	}
+	a := auditLog{
+		pathname: pathname,
+		maxBytes: maxBytes,
+		maxFiles: maxFiles,
+	}
+	for _, pattern := range redact {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			Warning("audit redact pattern '%s': %v", pattern, err)
+			continue
+		}
+		a.redact = append(a.redact, compiled)
+	}
+	return &a
+}
+
+// write an audit entry for each request when the response is complete
+func withAudit(handler http.Handler, a *auditLog) http.Handler {
+	if a == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record := auditRecord{entry: message.AuditEntry{
+			Time:       time.Now(),
+			RemoteAddr: r.RemoteAddr,
+			Method:     r.Method,
+			Endpoint:   r.URL.Path,
+		}}
+		if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			cert := r.TLS.PeerCertificates[0]
+			sum := sha256.Sum256(cert.Raw)
+			record.entry.Client = cert.Subject.CommonName
+			record.entry.Fingerprint = hex.EncodeToString(sum[:])
+		}
+		r = r.WithContext(context.WithValue(r.Context(), auditKey{}, &record))
+		body := countingReader{ReadCloser: r.Body}
+		r.Body = &body
+		writer := auditWriter{ResponseWriter: w}
+		// a handler aborting with a panic is recorded before the panic continues
+		defer func() {
+			recovered := recover()
+			record.mutex.Lock()
+			entry := record.entry
+			record.mutex.Unlock()
+			entry.Status = writer.status
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+			switch {
+			case recovered != nil:
+				entry.Result = AUDIT_ABORTED
+			case entry.Result == "" && entry.Status < http.StatusBadRequest:
+				entry.Result = "success"
+			}
+			entry.BytesIn = body.count
+			entry.BytesOut = writer.count
+			entry.DurationSeconds = time.Since(entry.Time).Seconds()
+			entry.Args = a.redactArgs(entry.Args)
+			a.write(&entry)
+			if recovered != nil {
+				panic(recovered)
+			}
+		}()
+		handler.ServeHTTP(&writer, r)
+	})
+}
+
+type countingReader struct {
+	io.ReadCloser
+	count int64
+}
+
+func (c *countingReader) Read(buf []byte) (int, error) {
+	count, err := c.ReadCloser.Read(buf)
+	c.count += int64(count)
+	return count, err
+}
+
+// Unwrap allows http.ResponseController to flush and enable full duplex mode
+type auditWriter struct {
+	http.ResponseWriter
+	status int
+	count  int64
+}
+
+func (w *auditWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	count, err := w.ResponseWriter.Write(data)
+	w.count += int64(count)
+	return count, err
+}
+
+func (w *auditWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// annotate the request's audit entry; requests without one are not audited
+func annotate(r *http.Request, fn func(entry *message.AuditEntry)) {
+	record, ok := r.Context().Value(auditKey{}).(*auditRecord)
+	if !ok {
+		return
+	}
+	record.mutex.Lock()
+	defer record.mutex.Unlock()
+	fn(&record.entry)
+}
+
+func auditPath(r *http.Request, pathname string) {
+	annotate(r, func(entry *message.AuditEntry) {
+		entry.Paths = append(entry.Paths, pathname)
+	})
+}
+
+func auditCommand(r *http.Request, command string, args []string) {
+	annotate(r, func(entry *message.AuditEntry) {
+		entry.Command = command
+		entry.Args = args
+	})
+}
+
+func auditExitCode(r *http.Request, exitCode int) {
+	annotate(r, func(entry *message.AuditEntry) {
+		entry.ExitCode = &exitCode
+	})
+}
+
+func auditResult(r *http.Request, result string) {
+	annotate(r, func(entry *message.AuditEntry) {
+		entry.Result = result
+	})
+}
+
+func auditPolicy(r *http.Request, name string) {
+	annotate(r, func(entry *message.AuditEntry) {
+		entry.Policy = name
+	})
+}
+
+// redact the values of arguments naming secrets, such as --password value or
+// TOKEN=value, the passwords of URLs, and text matching the configured patterns
+func (a *auditLog) redactArgs(args []string) []string {
+	if len(args) == 0 {
+		return args
+	}
+	redacted := make([]string, len(args))
+	secretNext := false
+	for i, arg := range args {
+		switch {
+		case secretNext:
+			arg = message.REDACTED
+			secretNext = false
+		case strings.Contains(arg, "="):
+			name, _, _ := strings.Cut(arg, "=")
+			if message.IsSecretName(name) {
+				arg = name + "=" + message.REDACTED
+			}
+		case strings.HasPrefix(arg, "-") && message.IsSecretName(arg):
+			secretNext = true
+		}
+		arg = message.RedactURL(arg)
+		for _, pattern := range a.redact {
+			arg = pattern.ReplaceAllString(arg, message.REDACTED)
+		}
+		redacted[i] = arg
+	}
+	return redacted
+}
+
+func (a *auditLog) write(entry *message.AuditEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		Warning("audit: %v", Fatal(err))
+		return
+	}
+	line = append(line, '\n')
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	err = a.open()
+	if err == nil && a.maxBytes > 0 && a.size > 0 && a.size+int64(len(line)) > a.maxBytes {
+		err = a.rotate()
+	}
+	if err != nil {
+		Warning("audit: %v", err)
+		return
+	}
+	count, err := a.file.Write(line)
+	a.size += int64(count)
+	if err != nil {
+		Warning("audit: %v", Fatal(err))
+	}
+}
+
+// open the file unless it is open and still present at pathname
+func (a *auditLog) open() error {
+	if a.file != nil {
+		current, err := os.Stat(a.pathname)
+		open, openErr := a.file.Stat()
+		if err == nil && openErr == nil && os.SameFile(current, open) {
+			return nil
+		}
+		a.file.Close()
+		a.file = nil
+	}
+	err := os.MkdirAll(filepath.Dir(a.pathname), 0700)
+	if err != nil {
+		return Fatal(err)
+	}
+	file, err := os.OpenFile(a.pathname, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return Fatal(err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return Fatal(err)
+	}
+	a.file = file
+	a.size = info.Size()
+	return nil
+}
+
+func (a *auditLog) rotate() error {
+	a.file.Close()
+	a.file = nil
+	os.Remove(a.rotatedPath(a.maxFiles))
+	for i := a.maxFiles - 1; i > 0; i-- {
+		err := os.Rename(a.rotatedPath(i), a.rotatedPath(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return Fatal(err)
+		}
+	}
+	if a.maxFiles > 0 {
+		err := os.Rename(a.pathname, a.rotatedPath(1))
+		if err != nil {
+			return Fatal(err)
+		}
+	} else {
+		err := os.Remove(a.pathname)
+		if err != nil {
+			return Fatal(err)
+		}
+	}
+	return a.open()
+}
+
+func (a *auditLog) rotatedPath(index int) string {
+	return fmt.Sprintf("%s.%d", a.pathname, index)
+}
+
+func (a *auditLog) close() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.file != nil {
+		a.file.Close()
+		a.file = nil
+	}
+}
+
+// return the most recent entries matching the query, oldest first, reading the
+// rotated files before the current one
+func (a *auditLog) query(query *message.AuditQueryRequest) ([]message.AuditEntry, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DEFAULT_AUDIT_QUERY_LIMIT
+	}
+	entries := []message.AuditEntry{}
+	for i := a.maxFiles; i >= 0; i-- {
+		pathname := a.pathname
+		if i > 0 {
+			pathname = a.rotatedPath(i)
+		}
+		file, err := os.Open(pathname)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, Fatal(err)
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var entry message.AuditEntry
+			// a line being written may be incomplete
+			err := json.Unmarshal(scanner.Bytes(), &entry)
+			if err != nil {
+				continue
+			}
+			if matchAuditEntry(query, &entry) {
+				entries = append(entries, entry)
+				if len(entries) > 2*limit {
+					entries = append([]message.AuditEntry{}, entries[len(entries)-limit:]...)
+				}
+			}
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, Fatal(err)
+		}
+	}
+	if len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
+
+func matchAuditEntry(query *message.AuditQueryRequest, entry *message.AuditEntry) bool {
+	switch {
+	case !query.Since.IsZero() && entry.Time.Before(query.Since):
+		return false
+	case !query.Until.IsZero() && entry.Time.After(query.Until):
+		return false
+	case query.Client != "" && entry.Client != query.Client:
+		return false
+	case query.Endpoint != "" && !strings.HasPrefix(entry.Endpoint, query.Endpoint):
+		return false
+	case query.Command != "" && !strings.Contains(entry.Command, query.Command):
+		return false
+	case query.Failed && entry.Status < http.StatusBadRequest && (entry.ExitCode == nil || *entry.ExitCode == 0) && entry.Result != AUDIT_ABORTED:
+		return false
+	}
+	if query.Path != "" {
+		for _, pathname := range entry.Paths {
+			if strings.Contains(pathname, query.Path) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (s *WinexecServer) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.AuditQueryRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	if s.audit == nil {
+		fail(w, r, "audit log disabled", http.StatusNotFound)
+		return
+	}
+	entries, err := s.audit.query(&request)
+	if err != nil {
+		Warning("%v", err)
+		fail(w, r, "audit query failed", http.StatusInternalServerError)
+		return
+	}
+	response := message.AuditQueryResponse{
+		Success: true,
+		Message: fmt.Sprintf("%d entries", len(entries)),
+		Entries: entries,
+	}
+	succeed(w, r, &response)
+}
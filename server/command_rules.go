@@ -0,0 +1,268 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/rstms/winexec/message"
+	"io/fs"
+	"log"
+	"net/http"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var errCommandDenied = fmt.Errorf("command denied: %w", fs.ErrPermission)
+
+const RULE_MATCH_EXACT = "exact"
+const RULE_MATCH_GLOB = "glob"
+const RULE_MATCH_REGEX = "regex"
+
+// CommandRule allows a command when Command matches the requested command, or for a
+// bare name, the executable found on the server's PATH, and each argument matches the
+// pattern in the same position of Args; Match selects exact comparison, filepath.Match
+// globs or regular expressions matching the whole string, and ExtraArgs allows more
+// arguments than patterns; the request environment is limited to the variables named
+// by the path.Match patterns of AllowEnv, Env is appended to it, and Dir replaces the
+// request working directory when set
+type CommandRule struct {
+	Name      string   `mapstructure:"name"`
+	Match     string   `mapstructure:"match"`
+	Command   string   `mapstructure:"command"`
+	Args      []string `mapstructure:"args"`
+	ExtraArgs bool     `mapstructure:"extra_args"`
+	AllowEnv  []string `mapstructure:"allow_env"`
+	Env       []string `mapstructure:"env"`
+	Dir       string   `mapstructure:"dir"`
+}
+
+type commandRule struct {
+	CommandRule
+	command *regexp.Regexp
+	args    []*regexp.Regexp
+}
+
+// when enforced, a command matching no rule is denied; otherwise rules are only
+// evaluated by dry runs and commands run unchanged
+type commandRules struct {
+	enforce bool
+	rules   []*commandRule
+}
+
+type commandRulesKey struct{}
+
+func (rule *CommandRule) compile() (*commandRule, error) {
+	compiled := commandRule{CommandRule: *rule}
+	var err error
+	compiled.command, err = compilePattern(rule.Match, rule.Command)
+	if err != nil {
+		return nil, Fatalf("command rule '%s': %v", rule.Name, err)
+	}
+	for _, arg := range rule.Args {
+		pattern, err := compilePattern(rule.Match, arg)
+		if err != nil {
+			return nil, Fatalf("command rule '%s': %v", rule.Name, err)
+		}
+		compiled.args = append(compiled.args, pattern)
+	}
+	for _, pattern := range rule.AllowEnv {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return nil, Fatalf("command rule '%s': allow_env '%s': %v", rule.Name, pattern, err)
+		}
+	}
+	return &compiled, nil
+}
+
+// exact and glob patterns are checked when the rule is compiled and matched without
+// a regular expression, so only regex rules return a compiled pattern
+func compilePattern(match, pattern string) (*regexp.Regexp, error) {
+	switch match {
+	case "", RULE_MATCH_EXACT:
+		return nil, nil
+	case RULE_MATCH_GLOB:
+		_, err := filepath.Match(pattern, "")
+		return nil, err
+	case RULE_MATCH_REGEX:
+		return regexp.Compile("^(?:" + pattern + ")$")
+	}
+	return nil, fmt.Errorf("unknown match type '%s'", match)
+}
+
+func validateCommandRules(rules []CommandRule) error {
+	for _, rule := range rules {
+		_, err := rule.compile()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rules that fail to compile are skipped, so the commands they would allow are denied
+func newCommandRules(enforce bool, rules []CommandRule) *commandRules {
+	if !enforce && len(rules) == 0 {
+		return nil
+	}
+	c := commandRules{enforce: enforce}
+	for _, rule := range rules {
+		compiled, err := rule.compile()
+		if err != nil {
+			Warning("%v", err)
+			continue
+		}
+		c.rules = append(c.rules, compiled)
+	}
+	return &c
+}
+
+// add the command rules to each request's context for checkProcess
+func withCommandRules(handler http.Handler, rules *commandRules) http.Handler {
+	if rules == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), commandRulesKey{}, rules)))
+	})
+}
+
+// return the first rule allowing the command and the command to run, which is the
+// executable found on the PATH when the rule matched only that; a command path is
+// cleaned before matching, so .. elements cannot climb out of a pattern's directory,
+// and a relative path matches no rule
+func (c *commandRules) match(command string, args []string) (*commandRule, string) {
+	resolved := ""
+	if filepath.Base(command) == command {
+		resolved, _ = exec.LookPath(command)
+	} else {
+		command = filepath.Clean(command)
+		if !filepath.IsAbs(command) {
+			return nil, command
+		}
+	}
+	for _, rule := range c.rules {
+		if !rule.matchArgs(args) {
+			continue
+		}
+		if rule.matchString(rule.command, rule.Command, command) {
+			return rule, command
+		}
+		if resolved != "" && rule.matchString(rule.command, rule.Command, resolved) {
+			return rule, resolved
+		}
+	}
+	return nil, command
+}
+
+func (rule *commandRule) matchArgs(args []string) bool {
+	if len(args) < len(rule.Args) || (len(args) > len(rule.Args) && !rule.ExtraArgs) {
+		return false
+	}
+	for i, pattern := range rule.Args {
+		if !rule.matchString(rule.args[i], pattern, args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (rule *commandRule) matchString(compiled *regexp.Regexp, pattern, value string) bool {
+	switch rule.Match {
+	case RULE_MATCH_GLOB:
+		matched, _ := filepath.Match(pattern, value)
+		return matched
+	case RULE_MATCH_REGEX:
+		return compiled.MatchString(value)
+	}
+	return pattern == value
+}
+
+// keep the variables named by the rule's AllowEnv patterns
+func (rule *commandRule) filterEnv(env []string) []string {
+	filtered := []string{}
+	for _, variable := range env {
+		name, _, _ := strings.Cut(variable, "=")
+		for _, pattern := range rule.AllowEnv {
+			matched, _ := path.Match(pattern, name)
+			if matched {
+				filtered = append(filtered, variable)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+func handleExecCheck(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.CommandCheckRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	rule, err := evaluateProcess(r, &request.Command, request.Args, &request.Env, &request.ProcessOptions, true)
+	c, _ := r.Context().Value(commandRulesKey{}).(*commandRules)
+	response := message.CommandCheckResponse{
+		Success:  true,
+		Message:  "allowed",
+		Allowed:  err == nil,
+		Enforced: c != nil && c.enforce,
+		Rule:     rule,
+		Command:  request.Command,
+		Args:     request.Args,
+		Env:      request.Env,
+		Dir:      request.Dir,
+	}
+	if err != nil {
+		response.Message = "denied"
+		response.Reason = err.Error()
+	}
+	succeed(w, r, &response)
+}
+
+// check a process request against the client's policy and the command rules, and
+// resolve its working directory; when the rules are enforced, the matching rule's
+// command, environment and working directory are applied to the request
+func checkProcess(r *http.Request, command *string, args []string, env *[]string, options *message.ProcessOptions) error {
+	_, err := evaluateProcess(r, command, args, env, options, false)
+	return err
+}
+
+// a dry run applies the rules as if they were enforced, returning the matching rule's name
+func evaluateProcess(r *http.Request, command *string, args []string, env *[]string, options *message.ProcessOptions, dryRun bool) (string, error) {
+	auditCommand(r, *command, args)
+	p, ok := r.Context().Value(policyKey{}).(*policy)
+	if ok && !p.allowCommand(*command) {
+		return "", &fs.PathError{Op: "exec", Path: *command, Err: errNotAuthorized}
+	}
+	name := ""
+	c, ok := r.Context().Value(commandRulesKey{}).(*commandRules)
+	if ok {
+		rule, resolved := c.match(*command, args)
+		switch {
+		case rule == nil && (c.enforce || dryRun):
+			return "", &fs.PathError{Op: "exec", Path: *command, Err: errCommandDenied}
+		case rule == nil:
+			Warning("command '%s' matches no command rule", *command)
+		case c.enforce || dryRun:
+			name = rule.Name
+			*command = resolved
+			*env = slices.Concat(rule.filterEnv(*env), rule.Env)
+			if rule.Dir != "" {
+				options.Dir = rule.Dir
+			}
+		}
+	}
+	return name, resolveDir(r, options)
+}
@@ -3,10 +3,10 @@ package server
 import (
 	"encoding/json"
 	"github.com/rstms/winexec/message"
-	"github.com/rstms/winexec/ospath"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 )
 
 func failIfDir(pathname string, w http.ResponseWriter, r *http.Request) bool {
@@ -39,15 +39,29 @@ func handleDirectoryCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if Verbose {
-		log.Printf("%+v\n", request)
+		log.Printf("%s\n", message.Redacted(request))
 	}
 
-	pathname := ospath.LocalPath(request.Pathname)
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
 
 	response := message.DirectoryResponse{
 		Success:  true,
 		Pathname: pathname,
 	}
+	if request.NoParents {
+		err = os.Mkdir(pathname, request.Mode)
+		if err != nil {
+			failPath(w, r, err, "create failed")
+			return
+		}
+		response.Message = "created"
+		succeed(w, r, &response)
+		return
+	}
 	if failIfDir(pathname, w, r) {
 		return
 	}
@@ -73,10 +87,14 @@ func handleDirectoryDestroy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if Verbose {
-		log.Printf("%+v\n", request)
+		log.Printf("%s\n", message.Redacted(request))
 	}
 
-	pathname := ospath.LocalPath(request.Pathname)
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
 
 	response := message.DirectoryResponse{
 		Success:  true,
@@ -107,10 +125,14 @@ func handleDirectoryEntries(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if Verbose {
-		log.Printf("%+v\n", request)
+		log.Printf("%s\n", message.Redacted(request))
 	}
 
-	pathname := ospath.LocalPath(request.Pathname)
+	pathname, err := readPath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
 
 	if failIfNotDir(pathname, w, r) {
 		return
@@ -135,12 +157,7 @@ func handleDirectoryEntries(w http.ResponseWriter, r *http.Request) {
 			fail(w, r, "failed reading entry info", http.StatusInternalServerError)
 			return
 		}
-		response.Entries[entry.Name()] = message.DirectoryEntry{
-			Name:    entry.Name(),
-			Size:    info.Size(),
-			ModTime: info.ModTime(),
-			Mode:    entry.Type(),
-		}
+		response.Entries[entry.Name()] = fileStat(filepath.Join(pathname, entry.Name()), info)
 	}
 	succeed(w, r, &response)
 }
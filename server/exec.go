@@ -2,12 +2,13 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/rstms/winexec/message"
 	"log"
 	"net/http"
-	"os"
 	"os/exec"
 )
 
@@ -22,54 +23,78 @@ func handleExec(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if Verbose {
-		log.Printf("%+v\n", request)
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	err = checkProcess(r, &request.Command, request.Args, &request.Env, &request.ProcessOptions)
+	if err != nil {
+		failPath(w, r, err, "invalid process request")
+		return
 	}
 
-	command, exit, stdout, stderr, err := run(request.Env, request.Command, request.Args...)
+	response, err := run(&request)
 	if err != nil {
 		Warning("%v", Fatal(err))
 		fail(w, r, "exec failed", http.StatusBadRequest)
 		return
 	}
-	response := message.ExecResponse{
-		Success:  true,
-		Message:  "executed",
-		Command:  command,
-		ExitCode: exit,
-		Stdout:   stdout,
-		Stderr:   stderr,
-	}
-	succeed(w, r, &response)
+	auditExitCode(r, response.ExitCode)
+	succeed(w, r, response)
 }
 
-func run(env []string, command string, args ...string) (string, int, string, string, error) {
+func run(request *message.ExecRequest) (*message.ExecResponse, error) {
 	if Debug {
-		log.Printf("Run: %s %v\n", command, args)
+		log.Printf("Run: %s %v\n", request.Command, request.Args)
 	}
-	cmd := exec.Command(command, args...)
+	p := newProcess(context.Background(), request.Env, request.ProcessOptions, request.Command, request.Args...)
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	exitCode := 0
-	if len(env) > 0 {
-		cmd.Env = append(os.Environ(), env...)
+	p.cmd.Stdout = &stdout
+	p.cmd.Stderr = &stderr
+	if len(request.Stdin) > 0 {
+		p.cmd.Stdin = bytes.NewReader(request.Stdin)
 	}
-	err := cmd.Run()
+	err := p.cmd.Start()
 	if err != nil {
-		switch e := err.(type) {
-		case *exec.ExitError:
-			exitCode = e.ExitCode()
-			err = nil
-		default:
-			return "", -1, "", "", err
-		}
+		return nil, err
+	}
+	exitCode, timedOut, err := p.Wait()
+	if err != nil {
+		return nil, err
 	}
 	if Debug {
 		log.Printf("exitCode=%d\n", exitCode)
+		log.Printf("timedOut=%v\n", timedOut)
 		log.Printf("stdout=%s\n", stdout.String())
 		log.Printf("stderr=%s\n", stderr.String())
-		log.Printf("err=%v\n", err)
 	}
-	return fmt.Sprintf("%v", cmd), exitCode, stdout.String(), stderr.String(), err
+	response := message.ExecResponse{
+		Success:  true,
+		Message:  "executed",
+		Command:  fmt.Sprintf("%v", p.cmd),
+		ExitCode: exitCode,
+		TimedOut: timedOut,
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+	}
+	if timedOut {
+		response.Message = "timed out"
+	}
+	return &response, nil
+}
+
+// convert a Run or Wait error into a process exit code, passing through non-exit errors
+func exitStatus(err error) (int, error) {
+	if err != nil {
+		switch e := err.(type) {
+		case *exec.ExitError:
+			return e.ExitCode(), nil
+		default:
+			// the process exited normally but left its output pipes open
+			if errors.Is(err, exec.ErrWaitDelay) {
+				return 0, nil
+			}
+			return -1, err
+		}
+	}
+	return 0, nil
 }
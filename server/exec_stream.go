@@ -0,0 +1,172 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/rstms/winexec/message"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+)
+
+const EXEC_STREAM_BUFFER_SIZE = 32 * 1024
+
+func handleExecStream(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.ExecRequest
+	body, err := decodePreamble(r, &request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	err = checkProcess(r, &request.Command, request.Args, &request.Env, &request.ProcessOptions)
+	if err != nil {
+		failPath(w, r, err, "invalid process request")
+		return
+	}
+
+	// the process is killed if the client disconnects
+	p := newProcess(r.Context(), request.Env, request.ProcessOptions, request.Command, request.Args...)
+	cmd := p.cmd
+
+	// stdin is copied from the request body while output frames are written to the response
+	err = http.NewResponseController(w).EnableFullDuplex()
+	if err != nil && Debug {
+		log.Printf("EnableFullDuplex: %v\n", err)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "exec failed", http.StatusInternalServerError)
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "exec failed", http.StatusInternalServerError)
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "exec failed", http.StatusInternalServerError)
+		return
+	}
+	if Debug {
+		log.Printf("ExecStream: %v\n", cmd)
+	}
+	err = cmd.Start()
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "exec failed", http.StatusBadRequest)
+		return
+	}
+
+	go func() {
+		defer stdin.Close()
+		_, err := io.Copy(stdin, io.MultiReader(bytes.NewReader(request.Stdin), body))
+		if err != nil && Debug {
+			log.Printf("stdin copy: %v\n", err)
+		}
+	}()
+
+	frames := make(chan message.ExecFrame)
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go readFrames(stdout, message.EXEC_STDOUT, frames, &readers)
+	go readFrames(stderr, message.EXEC_STDERR, frames, &readers)
+	go func() {
+		readers.Wait()
+		close(frames)
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	stream := newFrameWriter(w)
+	stream.Flush()
+	for frame := range frames {
+		stream.Write(&frame)
+	}
+
+	exitCode, timedOut, err := p.Wait()
+	if err != nil {
+		Warning("%v", Fatal(err))
+		auditExitCode(r, exitCode)
+		stream.Write(&message.ExecFrame{Stream: message.EXEC_ERROR, ExitCode: exitCode, Message: err.Error()})
+		return
+	}
+	if Debug {
+		log.Printf("exitCode=%d\n", exitCode)
+	}
+	auditExitCode(r, exitCode)
+	stream.Write(&message.ExecFrame{Stream: message.EXEC_EXIT, ExitCode: exitCode, TimedOut: timedOut, Message: fmt.Sprintf("%v", cmd)})
+	if Verbose {
+		log.Printf("%s <- winexec stream exit [%d] %d bytes\n", r.RemoteAddr, exitCode, stream.count)
+	}
+}
+
+// send output from a process pipe as frames until EOF
+func readFrames(pipe io.Reader, name string, frames chan<- message.ExecFrame, readers *sync.WaitGroup) {
+	defer readers.Done()
+	buf := make([]byte, EXEC_STREAM_BUFFER_SIZE)
+	for {
+		count, err := pipe.Read(buf)
+		if count > 0 {
+			data := make([]byte, count)
+			copy(data, buf[:count])
+			frames <- message.ExecFrame{Stream: name, Data: data}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// write NDJSON frames to a response, flushing each one to the client
+type frameWriter struct {
+	encoder    *json.Encoder
+	controller *http.ResponseController
+	count      int64
+	failed     bool
+}
+
+func newFrameWriter(w http.ResponseWriter) *frameWriter {
+	return &frameWriter{
+		encoder:    json.NewEncoder(w),
+		controller: http.NewResponseController(w),
+	}
+}
+
+// send the response header so the client can begin streaming stdin before any output
+func (f *frameWriter) Flush() {
+	err := f.controller.Flush()
+	if err != nil {
+		Warning("stream flush failed: %v", err)
+		f.failed = true
+	}
+}
+
+// once the client has gone away, frames are discarded so the process pipes are still drained
+func (f *frameWriter) Write(frame *message.ExecFrame) {
+	if f.failed {
+		return
+	}
+	err := f.encoder.Encode(frame)
+	if err == nil {
+		err = f.controller.Flush()
+	}
+	if err != nil {
+		Warning("stream write failed: %v", err)
+		f.failed = true
+		return
+	}
+	f.count += int64(len(frame.Data))
+}
@@ -3,7 +3,6 @@ package server
 import (
 	"encoding/json"
 	"github.com/rstms/winexec/message"
-	"github.com/rstms/winexec/ospath"
 	"log"
 	"net/http"
 	"os"
@@ -21,9 +20,13 @@ func handleFileDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if Verbose {
-		log.Printf("%+v\n", request)
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
 	}
-	pathname := ospath.LocalPath(request.Pathname)
 	response := message.FileResponse{
 		Success:  true,
 		Message:  "deleted",
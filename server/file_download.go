@@ -2,8 +2,8 @@ package server
 
 import (
 	"encoding/json"
+	"github.com/rstms/winexec/checksum"
 	"github.com/rstms/winexec/message"
-	"github.com/rstms/winexec/ospath"
 	"log"
 	"net/http"
 	"os"
@@ -21,9 +21,13 @@ func handleFileDownload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if Verbose {
-		log.Printf("%+v\n", request)
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	srcPathname, err := readPath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
 	}
-	srcPathname := ospath.LocalPath(request.Pathname)
 
 	fileinfo, err := os.Stat(srcPathname)
 	if err != nil {
@@ -46,5 +50,14 @@ func handleFileDownload(w http.ResponseWriter, r *http.Request) {
 		Timestamp: fileinfo.ModTime(),
 		Mode:      fileinfo.Mode(),
 	}
+	hasher, err := checksum.New()
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "checksum failed", http.StatusInternalServerError)
+		return
+	}
+	hasher.Write(data)
+	response.SHA256 = hasher.Sum(checksum.SHA256)
+	response.SHA512 = hasher.Sum(checksum.SHA512)
 	succeed(w, r, &response)
 }
@@ -2,9 +2,9 @@ package server
 
 import (
 	"encoding/json"
+	"github.com/rstms/winexec/checksum"
 	"github.com/rstms/winexec/geturl"
 	"github.com/rstms/winexec/message"
-	"github.com/rstms/winexec/ospath"
 	"log"
 	"net/http"
 )
@@ -21,14 +21,23 @@ func (s *WinexecServer) handleFileGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if Verbose {
-		log.Printf("%+v\n", request)
+		log.Printf("%s\n", message.Redacted(request))
 	}
 
-	pathname := ospath.LocalPath(request.Pathname)
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
 
-	count, err := geturl.GetURL(pathname, request.URL, request.CA, request.Cert, request.Key)
+	count, hasher, err := geturl.GetURL(pathname, request.URL, request.CA, request.Cert, request.Key, request.SHA256, request.SHA512)
 	if err != nil {
 		Warning("%v", Fatal(err))
+		// GetURL returns the hasher with an error only when verification fails
+		if hasher != nil {
+			fail(w, r, "checksum mismatch", http.StatusBadRequest)
+			return
+		}
 		fail(w, r, "get request failed", http.StatusBadRequest)
 		return
 	}
@@ -40,6 +49,8 @@ func (s *WinexecServer) handleFileGet(w http.ResponseWriter, r *http.Request) {
 		Message:  "downloaded",
 		Pathname: pathname,
 		Bytes:    count,
+		SHA256:   hasher.Sum(checksum.SHA256),
+		SHA512:   hasher.Sum(checksum.SHA512),
 	}
 	succeed(w, r, &response)
 }
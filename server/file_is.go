@@ -3,7 +3,6 @@ package server
 import (
 	"encoding/json"
 	"github.com/rstms/winexec/message"
-	"github.com/rstms/winexec/ospath"
 	"log"
 	"net/http"
 )
@@ -20,9 +19,13 @@ func (s *WinexecServer) handleIsFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if Verbose {
-		log.Printf("%+v\n", request)
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	pathname, err := readPath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
 	}
-	pathname := ospath.LocalPath(request.Pathname)
 
 	response := message.IsResponse{
 		Success:  true,
@@ -45,10 +48,14 @@ func (s *WinexecServer) handleIsDir(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if Verbose {
-		log.Printf("%+v\n", request)
+		log.Printf("%s\n", message.Redacted(request))
 	}
 
-	pathname := ospath.LocalPath(request.Pathname)
+	pathname, err := readPath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
 
 	response := message.IsResponse{
 		Success:  true,
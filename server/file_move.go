@@ -0,0 +1,293 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/rstms/winexec/message"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// fail with 404 if the source is missing, returning its info otherwise
+func statSource(pathname string, w http.ResponseWriter, r *http.Request) (fs.FileInfo, bool) {
+	info, err := os.Lstat(pathname)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		if os.IsNotExist(err) {
+			fail(w, r, "source not found", http.StatusNotFound)
+			return nil, false
+		}
+		fail(w, r, "stat failed", http.StatusBadRequest)
+		return nil, false
+	}
+	return info, true
+}
+
+func failDestinationExists(pathname string, w http.ResponseWriter, r *http.Request) {
+	Warning("destination exists: '%s'", pathname)
+	fail(w, r, "destination exists", http.StatusConflict)
+}
+
+func handleFileRename(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.FileRenameRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	src, err := writePath(r, request.Source)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	dst, err := writePath(r, request.Destination)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	info, ok := statSource(src, w, r)
+	if !ok {
+		return
+	}
+	dstInfo, err := os.Lstat(dst)
+	if err == nil && (!request.Overwrite || dstInfo.IsDir()) {
+		failDestinationExists(dst, w, r)
+		return
+	}
+	err = os.Rename(src, dst)
+	if err != nil && isCrossDevice(err) {
+		// move between volumes by copying, then removing the source
+		err = copyPath(src, dst, info, true, sandboxCheck(r))
+		if err == nil {
+			err = os.RemoveAll(src)
+		}
+	}
+	if errors.Is(err, errPathRejected) {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "rename failed", http.StatusBadRequest)
+		return
+	}
+	response := message.FileResponse{
+		Success:  true,
+		Message:  "renamed",
+		Pathname: dst,
+	}
+	succeed(w, r, &response)
+}
+
+func handleFileCopy(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.FileCopyRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	src, err := readPath(r, request.Source)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	dst, err := writePath(r, request.Destination)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	info, ok := statSource(src, w, r)
+	if !ok {
+		return
+	}
+	if info.IsDir() {
+		if !request.Recursive {
+			Warning("copy source is a directory: '%s'", src)
+			fail(w, r, "source is a directory", http.StatusBadRequest)
+			return
+		}
+		if isWithin(dst, src) {
+			Warning("copy destination '%s' is within source '%s'", dst, src)
+			fail(w, r, "destination is within source", http.StatusBadRequest)
+			return
+		}
+	}
+	dstInfo, err := os.Lstat(dst)
+	if err == nil && (!request.Overwrite || dstInfo.IsDir() != info.IsDir()) {
+		failDestinationExists(dst, w, r)
+		return
+	}
+	err = copyPath(src, dst, info, request.Overwrite, sandboxCheck(r))
+	if errors.Is(err, errPathRejected) {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "copy failed", http.StatusBadRequest)
+		return
+	}
+	response := message.FileResponse{
+		Success:  true,
+		Message:  "copied",
+		Pathname: dst,
+	}
+	succeed(w, r, &response)
+}
+
+// return true if pathname is dir or below it
+func isWithin(pathname, dir string) bool {
+	pathname, err := filepath.Abs(pathname)
+	if err != nil {
+		return false
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	return pathname == dir || strings.HasPrefix(pathname, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// copy a file, symlink or directory tree, keeping modes and modification times; an
+// existing destination directory must not be a symlink, so the copy cannot write
+// outside dst, and symlinks within a tree are copied only when they point within it;
+// check validates each destination path and symlink target against the sandbox
+func copyPath(src, dst string, info fs.FileInfo, overwrite bool, check func(pathname string, write bool) error) error {
+	if !info.IsDir() {
+		return copyEntry(src, dst, info, overwrite, check)
+	}
+	dirs := []string{}
+	dirTimes := map[string]time.Time{}
+	err := filepath.WalkDir(src, func(pathname string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(src, pathname)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relative)
+		err = check(target, true)
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			err := copyDir(target, info.Mode().Perm())
+			if err != nil {
+				return err
+			}
+			dirs = append(dirs, target)
+			dirTimes[target] = info.ModTime()
+			return nil
+		}
+		if info.Mode()&fs.ModeSymlink != 0 && !linkWithin(pathname, src) {
+			Warning("not copying symlink outside the copied tree: '%s'", pathname)
+			return nil
+		}
+		return copyEntry(pathname, target, info, overwrite, check)
+	})
+	if err != nil {
+		return err
+	}
+	// directory times are set last since copying into a directory changes them
+	slices.Reverse(dirs)
+	for _, dir := range dirs {
+		err := os.Chtimes(dir, time.Time{}, dirTimes[dir])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// create a directory, or use an existing one that is not a symlink
+func copyDir(pathname string, mode fs.FileMode) error {
+	info, err := os.Lstat(pathname)
+	if err == nil {
+		if !info.IsDir() {
+			return &fs.PathError{Op: "copy", Path: pathname, Err: fs.ErrExist}
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+	return os.Mkdir(pathname, mode)
+}
+
+// return true if the symlink's target is within root
+func linkWithin(link, root string) bool {
+	target, err := os.Readlink(link)
+	if err != nil {
+		return false
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(link), target)
+	}
+	return isWithin(target, root)
+}
+
+func copyEntry(src, dst string, info fs.FileInfo, overwrite bool, check func(pathname string, write bool) error) error {
+	dstInfo, err := os.Lstat(dst)
+	if err == nil {
+		if !overwrite || dstInfo.IsDir() {
+			return &fs.PathError{Op: "copy", Path: dst, Err: fs.ErrExist}
+		}
+	}
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		resolved := target
+		if !filepath.IsAbs(resolved) {
+			resolved = filepath.Join(filepath.Dir(dst), target)
+		}
+		err = check(resolved, false)
+		if err != nil {
+			return err
+		}
+		if dstInfo != nil {
+			err := os.Remove(dst)
+			if err != nil {
+				return err
+			}
+		}
+		return os.Symlink(target, dst)
+	case info.Mode().IsRegular():
+		file, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, _, err = writeFileAtomic(dst, file, info.Mode(), info.ModTime(), "", "")
+		return err
+	}
+	Warning("not copying special file: '%s'", src)
+	return nil
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/rstms/winexec/message"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+)
+
+// missing, existing and inaccessible paths and paths outside the sandbox are reported
+// with distinct status codes so the client can return them as *fs.PathError values
+func failPath(w http.ResponseWriter, r *http.Request, err error, failMessage string) {
+	Warning("%v", Fatal(err))
+	switch {
+	case errors.Is(err, errPathRejected):
+		fail(w, r, "path rejected", message.STATUS_PATH_REJECTED)
+	case errors.Is(err, fs.ErrNotExist):
+		fail(w, r, "not found", http.StatusNotFound)
+	case errors.Is(err, fs.ErrExist):
+		fail(w, r, "file exists", http.StatusConflict)
+	case errors.Is(err, fs.ErrPermission):
+		fail(w, r, "permission denied", http.StatusForbidden)
+	default:
+		fail(w, r, failMessage, http.StatusBadRequest)
+	}
+}
+
+func succeedPath(w http.ResponseWriter, r *http.Request, pathname, responseMessage string) {
+	response := message.FileResponse{
+		Success:  true,
+		Message:  responseMessage,
+		Pathname: pathname,
+	}
+	succeed(w, r, &response)
+}
+
+func handleFileOpen(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.FileOpenRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	resolve := readPath
+	if request.Write || request.Create || request.Truncate {
+		resolve = writePath
+	}
+	pathname, err := resolve(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	flag := os.O_RDONLY
+	switch {
+	case request.Read && request.Write:
+		flag = os.O_RDWR
+	case request.Write:
+		flag = os.O_WRONLY
+	}
+	if request.Create {
+		flag |= os.O_CREATE
+	}
+	if request.Exclusive {
+		flag |= os.O_EXCL
+	}
+	if request.Truncate {
+		flag |= os.O_TRUNC
+	}
+	file, err := os.OpenFile(pathname, flag, request.Mode.Perm())
+	if err != nil {
+		failPath(w, r, err, "open failed")
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		failPath(w, r, err, "stat failed")
+		return
+	}
+	response := message.StatResponse{
+		Success:  true,
+		Message:  "opened",
+		Pathname: pathname,
+		Stat:     fileStat(pathname, info),
+	}
+	succeed(w, r, &response)
+}
+
+func handleFileWrite(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.FileWriteRequest
+	body, err := decodePreamble(r, &request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	if request.Offset < 0 {
+		Warning("invalid offset: %d", request.Offset)
+		fail(w, r, "invalid offset", http.StatusBadRequest)
+		return
+	}
+	file, err := os.OpenFile(pathname, os.O_WRONLY, 0)
+	if err != nil {
+		failPath(w, r, err, "open failed")
+		return
+	}
+	count, err := io.Copy(io.NewOffsetWriter(file, request.Offset), body)
+	if err != nil {
+		file.Close()
+		failPath(w, r, err, "write failed")
+		return
+	}
+	err = file.Close()
+	if err != nil {
+		failPath(w, r, err, "write failed")
+		return
+	}
+	response := message.FileResponse{
+		Success:  true,
+		Message:  "written",
+		Pathname: pathname,
+		Bytes:    count,
+	}
+	succeed(w, r, &response)
+}
+
+func handleFileTruncate(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.FileTruncateRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	err = os.Truncate(pathname, request.Size)
+	if err != nil {
+		failPath(w, r, err, "truncate failed")
+		return
+	}
+	succeedPath(w, r, pathname, "truncated")
+}
+
+func handleFileChmod(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.FileChmodRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	err = os.Chmod(pathname, request.Mode)
+	if err != nil {
+		failPath(w, r, err, "chmod failed")
+		return
+	}
+	succeedPath(w, r, pathname, "changed mode")
+}
+
+func handleFileChown(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.FileChownRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	err = os.Chown(pathname, request.UID, request.GID)
+	if err != nil {
+		failPath(w, r, err, "chown failed")
+		return
+	}
+	succeedPath(w, r, pathname, "changed owner")
+}
+
+func handleFileChtimes(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.FileChtimesRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	err = os.Chtimes(pathname, request.AccessTime, request.ModTime)
+	if err != nil {
+		failPath(w, r, err, "chtimes failed")
+		return
+	}
+	succeedPath(w, r, pathname, "changed times")
+}
+
+func handleFileRemove(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.FileRemoveRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	if request.Recursive {
+		err = os.RemoveAll(pathname)
+	} else {
+		err = os.Remove(pathname)
+	}
+	if err != nil {
+		failPath(w, r, err, "remove failed")
+		return
+	}
+	succeedPath(w, r, pathname, "removed")
+}
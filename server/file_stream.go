@@ -0,0 +1,229 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rstms/winexec/checksum"
+	"github.com/rstms/winexec/message"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func handleFileDownloadStream(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.FileDownloadRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	srcPathname, err := readPath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+
+	file, err := os.Open(srcPathname)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		if os.IsNotExist(err) {
+			fail(w, r, "not found", http.StatusNotFound)
+			return
+		}
+		fail(w, r, "open failed", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	fileinfo, err := file.Stat()
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "stat failed", http.StatusBadRequest)
+		return
+	}
+	if !fileinfo.Mode().IsRegular() {
+		Warning("not a file: %s", srcPathname)
+		fail(w, r, "not a file", http.StatusBadRequest)
+		return
+	}
+
+	size := fileinfo.Size()
+	if request.Offset < 0 || request.Offset > size || request.Length < 0 {
+		Warning("invalid range: offset=%d length=%d size=%d", request.Offset, request.Length, size)
+		fail(w, r, "invalid range", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	length := size - request.Offset
+	if request.Length > 0 {
+		length = min(request.Length, length)
+	}
+	_, err = file.Seek(request.Offset, io.SeekStart)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "seek failed", http.StatusInternalServerError)
+		return
+	}
+
+	hasher, err := checksum.New()
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "checksum failed", http.StatusInternalServerError)
+		return
+	}
+
+	// Content-Length is omitted so the digests of the bytes sent can follow the body as trailers
+	header := w.Header()
+	header.Set("Content-Type", "application/octet-stream")
+	header.Set("Trailer", message.HEADER_SHA256+", "+message.HEADER_SHA512)
+	header.Set(message.HEADER_PATHNAME, srcPathname)
+	header.Set(message.HEADER_SIZE, fmt.Sprintf("%d", size))
+	header.Set(message.HEADER_OFFSET, fmt.Sprintf("%d", request.Offset))
+	header.Set(message.HEADER_LENGTH, fmt.Sprintf("%d", length))
+	header.Set(message.HEADER_MODE, fmt.Sprintf("%d", fileinfo.Mode()))
+	header.Set(message.HEADER_TIMESTAMP, fileinfo.ModTime().Format(time.RFC3339Nano))
+	w.WriteHeader(http.StatusOK)
+	count, err := io.CopyN(io.MultiWriter(w, hasher), file, length)
+	if err != nil {
+		Warning("download stream failed after %d bytes: %v", count, err)
+		return
+	}
+	header.Set(message.HEADER_SHA256, hasher.Sum(checksum.SHA256))
+	header.Set(message.HEADER_SHA512, hasher.Sum(checksum.SHA512))
+	if Verbose {
+		log.Printf("%s <- winexec download stream [200] %s %d bytes\n", r.RemoteAddr, srcPathname, count)
+	}
+}
+
+// the request body is a FileUploadRequest line followed by the file content; the
+// content is written to a temporary file which replaces the target when complete
+func handleFileUploadStream(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.FileUploadRequest
+	body, err := decodePreamble(r, &request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	if IsFile(pathname) {
+		if !request.Force {
+			Warning("file exists: '%s'", pathname)
+			fail(w, r, "file exists", http.StatusBadRequest)
+			return
+		}
+	}
+	if failIfDir(pathname, w, r) {
+		return
+	}
+
+	count, hasher, err := writeFileAtomic(pathname, body, request.Mode, request.Timestamp, request.SHA256, request.SHA512)
+	if err != nil {
+		failWrite(w, r, err)
+		return
+	}
+	response := message.FileResponse{
+		Success:  true,
+		Message:  "uploaded",
+		Pathname: pathname,
+		Bytes:    count,
+		SHA256:   hasher.Sum(checksum.SHA256),
+		SHA512:   hasher.Sum(checksum.SHA512),
+	}
+	succeed(w, r, &response)
+}
+
+// write the content to a temporary file in the target directory, then rename it into place
+// if the expected digests match; the returned Hasher holds the digests of the content
+func writeFileAtomic(pathname string, content io.Reader, mode os.FileMode, timestamp time.Time, sha256, sha512 string) (int64, *checksum.Hasher, error) {
+	dir, name := filepath.Split(pathname)
+	if dir == "" {
+		dir = "."
+	}
+	hasher, err := checksum.New()
+	if err != nil {
+		return 0, nil, err
+	}
+	file, err := os.CreateTemp(dir, "."+name+".winexec-*")
+	if err != nil {
+		return 0, nil, err
+	}
+	tempPathname := file.Name()
+	defer os.Remove(tempPathname)
+	count, err := io.Copy(io.MultiWriter(file, hasher), content)
+	if err != nil {
+		file.Close()
+		return count, nil, err
+	}
+	err = file.Close()
+	if err != nil {
+		return count, nil, err
+	}
+	err = hasher.VerifyAll(sha256, sha512)
+	if err != nil {
+		return count, hasher, &checksumError{err}
+	}
+	err = finishFile(tempPathname, pathname, mode, timestamp)
+	if err != nil {
+		return count, nil, err
+	}
+	return count, hasher, nil
+}
+
+// checksumError distinguishes a digest mismatch from a failure writing the file
+type checksumError struct {
+	err error
+}
+
+func (e *checksumError) Error() string {
+	return e.err.Error()
+}
+
+// fail the request with a status indicating whether the write or the checksum failed
+func failWrite(w http.ResponseWriter, r *http.Request, err error) {
+	Warning("%v", Fatal(err))
+	var mismatch *checksumError
+	if errors.As(err, &mismatch) {
+		fail(w, r, "checksum mismatch", http.StatusUnprocessableEntity)
+		return
+	}
+	fail(w, r, "write failed", http.StatusBadRequest)
+}
+
+// set the mode and timestamp of a completed temporary file and rename it into place
+func finishFile(tempPathname, pathname string, mode os.FileMode, timestamp time.Time) error {
+	if mode.Perm() != 0 {
+		err := os.Chmod(tempPathname, mode.Perm())
+		if err != nil {
+			return err
+		}
+	}
+	if !timestamp.IsZero() {
+		err := os.Chtimes(tempPathname, time.Time{}, timestamp)
+		if err != nil {
+			return err
+		}
+	}
+	return os.Rename(tempPathname, pathname)
+}
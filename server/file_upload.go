@@ -1,13 +1,12 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
+	"github.com/rstms/winexec/checksum"
 	"github.com/rstms/winexec/message"
-	"github.com/rstms/winexec/ospath"
 	"log"
 	"net/http"
-	"os"
-	"time"
 )
 
 func handleFileUpload(w http.ResponseWriter, r *http.Request) {
@@ -22,36 +21,35 @@ func handleFileUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if Verbose {
-		log.Printf("%+v\n", request)
+		log.Printf("%s\n", message.Redacted(request))
 	}
 
-	if IsFile(request.Pathname) {
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+
+	if IsFile(pathname) {
 		if !request.Force {
-			Warning("file exists: '%s'", request.Pathname)
+			Warning("file exists: '%s'", pathname)
 			fail(w, r, "file exists", http.StatusBadRequest)
 			return
 		}
 	}
 
-	pathname := ospath.LocalPath(request.Pathname)
-
-	err = os.WriteFile(pathname, request.Content, request.Mode)
+	count, hasher, err := writeFileAtomic(pathname, bytes.NewReader(request.Content), request.Mode, request.Timestamp, request.SHA256, request.SHA512)
 	if err != nil {
-		Warning("%v", Fatal(err))
-		fail(w, r, "write failed", http.StatusBadRequest)
+		failWrite(w, r, err)
 		return
 	}
-	err = os.Chtimes(pathname, time.Time{}, request.Timestamp)
-	if err != nil {
-		Warning("%v", Fatal(err))
-		fail(w, r, "time update failed", http.StatusBadRequest)
-		return
-
-	}
 	response := message.FileResponse{
 		Success:  true,
 		Message:  "uploaded",
 		Pathname: pathname,
+		Bytes:    count,
+		SHA256:   hasher.Sum(checksum.SHA256),
+		SHA512:   hasher.Sum(checksum.SHA512),
 	}
 	succeed(w, r, &response)
 }
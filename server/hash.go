@@ -0,0 +1,56 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/rstms/winexec/checksum"
+	"github.com/rstms/winexec/message"
+	"log"
+	"net/http"
+)
+
+func handleHash(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.HashRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	algorithms := request.Algorithms
+	if len(algorithms) == 0 {
+		algorithms = []string{checksum.SHA256}
+	}
+	_, err = checksum.New(algorithms...)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "unsupported algorithm", http.StatusBadRequest)
+		return
+	}
+	pathname, err := readPath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	files, err := checksum.Path(pathname, algorithms...)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "hash failed", http.StatusBadRequest)
+		return
+	}
+	response := message.HashResponse{
+		Success:  true,
+		Message:  "hashed",
+		Pathname: pathname,
+		Files:    make(map[string]message.FileHash),
+	}
+	for name, sums := range files {
+		response.Files[name] = message.FileHash(sums)
+	}
+	succeed(w, r, &response)
+}
@@ -0,0 +1,351 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/rstms/winexec/message"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// a job is visible only to clients authorized by the policy that started it
+type job struct {
+	mutex   sync.Mutex
+	status  message.JobStatus
+	owner   string
+	process *process
+	stdout  *outputBuffer
+	stderr  *outputBuffer
+	done    chan struct{}
+}
+
+// outputBuffer captures process output up to a limit, counting what is dropped
+type outputBuffer struct {
+	mutex   sync.Mutex
+	data    []byte
+	limit   int
+	dropped int64
+}
+
+func (b *outputBuffer) Write(data []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	room := max(b.limit-len(b.data), 0)
+	count := min(room, len(data))
+	b.data = append(b.data, data[:count]...)
+	b.dropped += int64(len(data) - count)
+	return len(data), nil
+}
+
+// return the output following offset and the offset of the next unread byte
+func (b *outputBuffer) ReadFrom(offset int64) ([]byte, int64, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	offset = min(max(offset, 0), int64(len(b.data)))
+	return slices.Clone(b.data[offset:]), int64(len(b.data)), b.dropped > 0
+}
+
+func newID() (string, error) {
+	id := make([]byte, 8)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// return the name of the policy authorizing the request, which is empty when no
+// policies are configured
+func jobOwner(r *http.Request) string {
+	p, ok := r.Context().Value(policyKey{}).(*policy)
+	if ok {
+		return p.Name
+	}
+	return ""
+}
+
+// start the process and track it in the job table until it exits
+func (s *WinexecServer) startJob(p *process, spawned bool, owner string) (*job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	j := job{
+		owner:   owner,
+		process: p,
+		done:    make(chan struct{}),
+	}
+	if !spawned {
+		j.stdout = &outputBuffer{limit: s.jobOutputLimit}
+		j.stderr = &outputBuffer{limit: s.jobOutputLimit}
+		p.cmd.Stdout = j.stdout
+		p.cmd.Stderr = j.stderr
+	}
+	err = p.cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	j.status = message.JobStatus{
+		ID:        id,
+		PID:       p.cmd.Process.Pid,
+		Command:   fmt.Sprintf("%v", p.cmd),
+		Spawned:   spawned,
+		Running:   true,
+		StartTime: time.Now(),
+	}
+	s.jobsLock.Lock()
+	s.jobs[id] = &j
+	s.jobsLock.Unlock()
+	if Debug {
+		log.Printf("job %s started: %s\n", id, message.Redacted(j.status))
+	}
+	go func() {
+		exitCode, timedOut, err := p.Wait()
+		j.mutex.Lock()
+		j.status.Running = false
+		j.status.ExitCode = exitCode
+		j.status.TimedOut = timedOut
+		j.status.EndTime = time.Now()
+		if err != nil {
+			j.status.Error = err.Error()
+		}
+		if Verbose {
+			log.Printf("job %s exited: %s\n", id, message.Redacted(j.status))
+		}
+		j.mutex.Unlock()
+		close(j.done)
+	}()
+	return &j, nil
+}
+
+func (j *job) Status() message.JobStatus {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.status
+}
+
+func (j *job) Kill() {
+	j.mutex.Lock()
+	if j.status.Running {
+		j.status.Killed = true
+	}
+	j.mutex.Unlock()
+	j.process.Kill()
+}
+
+// a job started under another policy is not found
+func (s *WinexecServer) getJob(r *http.Request, id string) (*job, bool) {
+	s.jobsLock.Lock()
+	defer s.jobsLock.Unlock()
+	j, ok := s.jobs[id]
+	if !ok || j.owner != jobOwner(r) {
+		return nil, false
+	}
+	return j, true
+}
+
+// discard finished jobs once they are older than the retention period
+func (s *WinexecServer) checkJobs() {
+	s.jobsLock.Lock()
+	defer s.jobsLock.Unlock()
+	expired := time.Now().Add(-time.Duration(s.jobRetentionSeconds) * time.Second)
+	for id, j := range s.jobs {
+		status := j.Status()
+		if !status.Running && status.EndTime.Before(expired) {
+			if s.debug {
+				log.Printf("discarding job %s\n", id)
+			}
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func (s *WinexecServer) handleJobStart(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.ExecRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	err = checkProcess(r, &request.Command, request.Args, &request.Env, &request.ProcessOptions)
+	if err != nil {
+		failPath(w, r, err, "invalid process request")
+		return
+	}
+	p := newProcess(context.Background(), request.Env, request.ProcessOptions, request.Command, request.Args...)
+	if len(request.Stdin) > 0 {
+		p.cmd.Stdin = bytes.NewReader(request.Stdin)
+	}
+	j, err := s.startJob(p, false, jobOwner(r))
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "job start failed", http.StatusBadRequest)
+		return
+	}
+	response := message.JobResponse{
+		Success: true,
+		Message: "started",
+		Job:     j.Status(),
+	}
+	succeed(w, r, &response)
+}
+
+func (s *WinexecServer) handleJobList(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	response := message.JobListResponse{
+		Success: true,
+		Message: "jobs",
+		Jobs:    []message.JobStatus{},
+	}
+	owner := jobOwner(r)
+	s.jobsLock.Lock()
+	for _, j := range s.jobs {
+		if j.owner == owner {
+			response.Jobs = append(response.Jobs, j.Status())
+		}
+	}
+	s.jobsLock.Unlock()
+	slices.SortFunc(response.Jobs, func(a, b message.JobStatus) int {
+		c := a.StartTime.Compare(b.StartTime)
+		if c == 0 {
+			c = strings.Compare(a.ID, b.ID)
+		}
+		return c
+	})
+	succeed(w, r, &response)
+}
+
+// decode a JobRequest and look up the job, failing the request if it is not found
+func (s *WinexecServer) requestJob(w http.ResponseWriter, r *http.Request) (*message.JobRequest, *job, bool) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.JobRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return nil, nil, false
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	j, ok := s.getJob(r, request.ID)
+	if !ok {
+		Warning("job not found: %s", request.ID)
+		fail(w, r, "job not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+	return &request, j, true
+}
+
+func (s *WinexecServer) handleJobStatus(w http.ResponseWriter, r *http.Request) {
+	_, j, ok := s.requestJob(w, r)
+	if !ok {
+		return
+	}
+	response := message.JobResponse{
+		Success: true,
+		Message: "status",
+		Job:     j.Status(),
+	}
+	succeed(w, r, &response)
+}
+
+// wait for the job to exit, returning the current status if TimeoutSeconds elapses first
+func (s *WinexecServer) handleJobWait(w http.ResponseWriter, r *http.Request) {
+	request, j, ok := s.requestJob(w, r)
+	if !ok {
+		return
+	}
+	var timeout <-chan time.Time
+	if request.TimeoutSeconds > 0 {
+		timer := time.NewTimer(time.Duration(request.TimeoutSeconds) * time.Second)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	response := message.JobResponse{
+		Success: true,
+		Message: "exited",
+	}
+	select {
+	case <-j.done:
+	case <-timeout:
+		response.Message = "running"
+	case <-r.Context().Done():
+		return
+	}
+	response.Job = j.Status()
+	if !response.Job.Running {
+		auditExitCode(r, response.Job.ExitCode)
+	}
+	succeed(w, r, &response)
+}
+
+func (s *WinexecServer) handleJobKill(w http.ResponseWriter, r *http.Request) {
+	_, j, ok := s.requestJob(w, r)
+	if !ok {
+		return
+	}
+	j.Kill()
+	response := message.JobResponse{
+		Success: true,
+		Message: "killed",
+		Job:     j.Status(),
+	}
+	succeed(w, r, &response)
+}
+
+func (s *WinexecServer) handleJobOutput(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.JobOutputRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	j, ok := s.getJob(r, request.ID)
+	if !ok {
+		Warning("job not found: %s", request.ID)
+		fail(w, r, "job not found", http.StatusNotFound)
+		return
+	}
+	if j.stdout == nil {
+		fail(w, r, "spawned job output is not captured", http.StatusBadRequest)
+		return
+	}
+	response := message.JobOutputResponse{
+		Success: true,
+		Message: "output",
+		ID:      request.ID,
+		Running: j.Status().Running,
+	}
+	var stdoutTruncated, stderrTruncated bool
+	response.Stdout, response.StdoutOffset, stdoutTruncated = j.stdout.ReadFrom(request.StdoutOffset)
+	response.Stderr, response.StderrOffset, stderrTruncated = j.stderr.ReadFrom(request.StderrOffset)
+	response.Truncated = stdoutTruncated || stderrTruncated
+	succeed(w, r, &response)
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var errNotAuthorized = fmt.Errorf("not authorized: %w", fs.ErrPermission)
+
+// Policy grants access to clients whose certificate matches any of its subject common
+// names, subject alternative names, organizational units or SHA-256 fingerprints;
+// Endpoints and Commands are path.Match patterns for the request path and the command
+// or its base name, and empty lists place no restriction, except that the audit log is
+// only queried by policies whose Endpoints name it
+type Policy struct {
+	Name                string   `mapstructure:"name"`
+	CommonNames         []string `mapstructure:"common_names"`
+	SubjectAltNames     []string `mapstructure:"subject_alt_names"`
+	OrganizationalUnits []string `mapstructure:"organizational_units"`
+	Fingerprints        []string `mapstructure:"fingerprints"`
+	Endpoints           []string `mapstructure:"endpoints"`
+	ReadOnlyRoots       []string `mapstructure:"read_only_roots"`
+	ReadWriteRoots      []string `mapstructure:"read_write_roots"`
+	Commands            []string `mapstructure:"commands"`
+}
+
+type policy struct {
+	Policy
+	sandbox *sandbox
+}
+
+type policyKey struct{}
+
+// when policies are configured, each request is authorized by the first policy matching
+// the client certificate, and a client matching no policy is refused
+func withPolicies(handler http.Handler, policies []Policy) http.Handler {
+	if len(policies) == 0 {
+		return handler
+	}
+	compiled := []*policy{}
+	for _, p := range policies {
+		compiled = append(compiled, &policy{
+			Policy:  p,
+			sandbox: newSandbox(p.ReadOnlyRoots, p.ReadWriteRoots),
+		})
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			Warning("%s %s %s: no client certificate", r.RemoteAddr, r.Method, r.URL.Path)
+			fail(w, r, "client certificate required", http.StatusForbidden)
+			return
+		}
+		cert := r.TLS.PeerCertificates[0]
+		p := matchPolicy(compiled, cert)
+		if p == nil {
+			Warning("%s %s %s: no policy for client '%s'", r.RemoteAddr, r.Method, r.URL.Path, cert.Subject.CommonName)
+			fail(w, r, "not authorized", http.StatusForbidden)
+			return
+		}
+		if !p.allowEndpoint(r.URL.Path) {
+			Warning("%s %s %s: endpoint not allowed by policy '%s'", r.RemoteAddr, r.Method, r.URL.Path, p.Name)
+			fail(w, r, "not authorized", http.StatusForbidden)
+			return
+		}
+		if Verbose {
+			log.Printf("%s client '%s' authorized by policy '%s'\n", r.RemoteAddr, cert.Subject.CommonName, p.Name)
+		}
+		auditPolicy(r, p.Name)
+		r = r.WithContext(context.WithValue(r.Context(), policyKey{}, p))
+		if p.sandbox != nil {
+			r = addSandbox(r, p.sandbox)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func matchPolicy(policies []*policy, cert *x509.Certificate) *policy {
+	sum := sha256.Sum256(cert.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+	altNames := slices.Clone(cert.DNSNames)
+	altNames = append(altNames, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		altNames = append(altNames, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		altNames = append(altNames, uri.String())
+	}
+	for _, p := range policies {
+		if slices.Contains(p.CommonNames, cert.Subject.CommonName) {
+			return p
+		}
+		for _, name := range altNames {
+			if slices.Contains(p.SubjectAltNames, name) {
+				return p
+			}
+		}
+		for _, unit := range cert.Subject.OrganizationalUnit {
+			if slices.Contains(p.OrganizationalUnits, unit) {
+				return p
+			}
+		}
+		// fingerprints may be written in upper case or with colon separators
+		for _, f := range p.Fingerprints {
+			if strings.ToLower(strings.ReplaceAll(f, ":", "")) == fingerprint {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+func (p *policy) allowEndpoint(requestPath string) bool {
+	if strings.HasPrefix(requestPath, "/audit/") {
+		return matchAny(p.Endpoints, requestPath)
+	}
+	return len(p.Endpoints) == 0 || matchAny(p.Endpoints, requestPath)
+}
+
+func (p *policy) allowCommand(command string) bool {
+	return len(p.Commands) == 0 || matchAny(p.Commands, command) || matchAny(p.Commands, filepath.Base(command))
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, name)
+		if err != nil {
+			Warning("invalid policy pattern '%s': %v", pattern, err)
+			continue
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// streaming request bodies begin with a JSON request line followed by raw data
+func decodePreamble(r *http.Request, request any) (io.Reader, error) {
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(request)
+	if err != nil {
+		return nil, err
+	}
+	body := bufio.NewReader(io.MultiReader(decoder.Buffered(), r.Body))
+	next, err := body.ReadByte()
+	switch {
+	case err == io.EOF:
+		return body, nil
+	case err != nil:
+		return nil, err
+	case next != '\n':
+		body.UnreadByte()
+	}
+	return body, nil
+}
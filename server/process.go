@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/rstms/winexec/message"
+	"github.com/rstms/winexec/ospath"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// after a timeout kill, output pipes held open by orphaned children are closed after this delay
+const PROCESS_WAIT_DELAY_SECONDS = 5
+
+type process struct {
+	cmd     *exec.Cmd
+	ctx     context.Context
+	cancel  context.CancelFunc
+	grace   time.Duration
+	exited  chan struct{}
+	timeout time.Duration
+}
+
+// create a command that is bound to the parent context and the request time limit
+func newProcess(parent context.Context, env []string, options message.ProcessOptions, command string, args ...string) *process {
+	p := process{
+		grace:   time.Duration(options.KillGraceSeconds) * time.Second,
+		timeout: time.Duration(options.TimeoutSeconds) * time.Second,
+		exited:  make(chan struct{}),
+	}
+	if p.timeout > 0 {
+		p.ctx, p.cancel = context.WithTimeout(parent, p.timeout)
+	} else {
+		p.ctx, p.cancel = context.WithCancel(parent)
+	}
+	p.cmd = exec.CommandContext(p.ctx, command, args...)
+	if options.Dir != "" {
+		p.cmd.Dir = ospath.LocalPath(options.Dir)
+	}
+	if len(env) > 0 {
+		p.cmd.Env = append(os.Environ(), env...)
+	}
+	setProcessGroup(p.cmd)
+	p.cmd.Cancel = p.terminate
+	if p.timeout > 0 {
+		p.cmd.WaitDelay = p.grace + PROCESS_WAIT_DELAY_SECONDS*time.Second
+	}
+	return &p
+}
+
+// called by exec.Cmd when the context is done
+func (p *process) terminate() error {
+	if p.grace == 0 {
+		return killProcessTree(p.cmd.Process)
+	}
+	if Debug {
+		log.Printf("terminating process tree %d\n", p.cmd.Process.Pid)
+	}
+	err := terminateProcessTree(p.cmd.Process)
+	if err != nil {
+		Warning("terminate failed: %v", err)
+	}
+	go func() {
+		select {
+		case <-p.exited:
+		case <-time.After(p.grace):
+			err := killProcessTree(p.cmd.Process)
+			if err != nil {
+				Warning("kill failed: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// request termination of the process tree using the kill policy
+func (p *process) Kill() {
+	p.cancel()
+}
+
+// wait for the process to exit, returning the exit code and whether the time limit expired
+func (p *process) Wait() (int, bool, error) {
+	err := p.cmd.Wait()
+	close(p.exited)
+	timedOut := errors.Is(p.ctx.Err(), context.DeadlineExceeded)
+	p.cancel()
+	exitCode, err := exitStatus(err)
+	return exitCode, timedOut, err
+}
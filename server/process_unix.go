@@ -0,0 +1,26 @@
+//go:build !windows
+
+package server
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// start the command in its own process group so the whole tree can be signaled
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// command lines are only used on windows
+func setCommandLine(cmd *exec.Cmd, commandLine string) {
+}
+
+func terminateProcessTree(process *os.Process) error {
+	return syscall.Kill(-process.Pid, syscall.SIGTERM)
+}
+
+func killProcessTree(process *os.Process) error {
+	return syscall.Kill(-process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// the command line is passed to the process unchanged rather than built from cmd.Args
+func setCommandLine(cmd *exec.Cmd, commandLine string) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CmdLine = commandLine
+}
+
+// taskkill /T signals the process and all of its descendants
+func terminateProcessTree(process *os.Process) error {
+	return exec.Command("taskkill", "/T", "/PID", fmt.Sprintf("%d", process.Pid)).Run()
+}
+
+func killProcessTree(process *os.Process) error {
+	return exec.Command("taskkill", "/F", "/T", "/PID", fmt.Sprintf("%d", process.Pid)).Run()
+}
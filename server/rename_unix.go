@@ -0,0 +1,12 @@
+//go:build !windows
+
+package server
+
+import (
+	"errors"
+	"syscall"
+)
+
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
@@ -0,0 +1,10 @@
+package server
+
+import (
+	"errors"
+	"golang.org/x/sys/windows"
+)
+
+func isCrossDevice(err error) bool {
+	return errors.Is(err, windows.ERROR_NOT_SAME_DEVICE)
+}
@@ -0,0 +1,218 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/rstms/winexec/message"
+	"github.com/rstms/winexec/ospath"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var errPathRejected = errors.New("path rejected")
+
+// a sandbox restricts request paths to descendants of its roots; paths under a
+// read-only root may be read but not changed
+type sandbox struct {
+	readOnly  []string
+	readWrite []string
+}
+
+type sandboxKey struct{}
+
+// roots are canonicalized when the sandbox is created; a root that cannot be resolved
+// is kept as an absolute path, which only matches once it exists without symlinks
+func newSandbox(readOnly, readWrite []string) *sandbox {
+	if len(readOnly) == 0 && len(readWrite) == 0 {
+		return nil
+	}
+	b := sandbox{}
+	for _, root := range readOnly {
+		b.readOnly = append(b.readOnly, canonicalRoot(root))
+	}
+	for _, root := range readWrite {
+		b.readWrite = append(b.readWrite, canonicalRoot(root))
+	}
+	if Verbose {
+		log.Printf("sandbox read-only roots: %v read-write roots: %v\n", b.readOnly, b.readWrite)
+	}
+	return &b
+}
+
+func canonicalRoot(root string) string {
+	root, err := filepath.Abs(ospath.LocalPath(root))
+	if err != nil {
+		Warning("sandbox root: %v", err)
+		return filepath.Clean(root)
+	}
+	resolved, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		Warning("sandbox root: %v", err)
+		return root
+	}
+	return resolved
+}
+
+// add the sandbox to each request's context for readPath and writePath
+func withSandbox(handler http.Handler, b *sandbox) http.Handler {
+	if b == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, addSandbox(r, b))
+	})
+}
+
+// a request may carry several sandboxes, and a path must be within all of them
+func addSandbox(r *http.Request, b *sandbox) *http.Request {
+	sandboxes, _ := r.Context().Value(sandboxKey{}).([]*sandbox)
+	sandboxes = append(sandboxes[:len(sandboxes):len(sandboxes)], b)
+	return r.WithContext(context.WithValue(r.Context(), sandboxKey{}, sandboxes))
+}
+
+func resolvePath(r *http.Request, pathname string, write bool) (string, error) {
+	auditPath(r, pathname)
+	sandboxes, _ := r.Context().Value(sandboxKey{}).([]*sandbox)
+	if len(sandboxes) == 0 {
+		return ospath.LocalPath(pathname), nil
+	}
+	var resolved string
+	for _, b := range sandboxes {
+		var err error
+		resolved, err = b.resolve(pathname, write)
+		if err != nil {
+			return "", err
+		}
+	}
+	return resolved, nil
+}
+
+// return the local pathname for a request path that will be read
+func readPath(r *http.Request, pathname string) (string, error) {
+	return resolvePath(r, pathname, false)
+}
+
+// return the local pathname for a request path that will be created, changed or removed
+func writePath(r *http.Request, pathname string) (string, error) {
+	return resolvePath(r, pathname, true)
+}
+
+// return a function checking the paths a handler creates below a request path, such as
+// the entries of a copied tree, against the request's sandboxes
+func sandboxCheck(r *http.Request) func(pathname string, write bool) error {
+	sandboxes, _ := r.Context().Value(sandboxKey{}).([]*sandbox)
+	return func(pathname string, write bool) error {
+		for _, b := range sandboxes {
+			_, err := b.resolve(pathname, write)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// resolve the process working directory as a path that will be read
+func resolveDir(r *http.Request, options *message.ProcessOptions) error {
+	if options.Dir == "" {
+		return nil
+	}
+	dir, err := readPath(r, options.Dir)
+	if err != nil {
+		return err
+	}
+	options.Dir = dir
+	return nil
+}
+
+// the parent directory is canonicalized and the final element joined unresolved, so
+// handlers that do not follow links remove, rename or stat a symlink itself; the target
+// of a final symlink must also be within the sandbox, since other handlers follow it
+func (b *sandbox) resolve(pathname string, write bool) (string, error) {
+	local := ospath.LocalPath(pathname)
+	if !filepath.IsAbs(local) {
+		return "", &fs.PathError{Op: "resolve", Path: pathname, Err: errPathRejected}
+	}
+	for _, element := range strings.Split(filepath.ToSlash(local), "/") {
+		if element == ".." {
+			return "", &fs.PathError{Op: "resolve", Path: pathname, Err: errPathRejected}
+		}
+	}
+	local = filepath.Clean(local)
+	parent, err := canonicalPath(filepath.Dir(local))
+	if err != nil {
+		return "", &fs.PathError{Op: "resolve", Path: pathname, Err: err}
+	}
+	resolved := filepath.Join(parent, filepath.Base(local))
+	if !b.allow(resolved, write) {
+		return "", &fs.PathError{Op: "resolve", Path: pathname, Err: errPathRejected}
+	}
+	// windows reports junctions as irregular files rather than symlinks
+	info, err := os.Lstat(resolved)
+	if err == nil && info.Mode()&(fs.ModeSymlink|fs.ModeIrregular) != 0 {
+		target, err := canonicalPath(resolved)
+		if err != nil {
+			return "", &fs.PathError{Op: "resolve", Path: pathname, Err: err}
+		}
+		if !b.allow(target, write) {
+			return "", &fs.PathError{Op: "resolve", Path: pathname, Err: errPathRejected}
+		}
+	}
+	return resolved, nil
+}
+
+func (b *sandbox) allow(pathname string, write bool) bool {
+	for _, root := range b.readWrite {
+		if withinRoot(root, pathname) {
+			return true
+		}
+	}
+	if !write {
+		for _, root := range b.readOnly {
+			if withinRoot(root, pathname) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolve symlinks and junctions in the longest existing prefix of pathname, so a path
+// may name a file that does not exist yet; a dangling symlink is rejected since
+// creating a file through it would escape the sandbox
+func canonicalPath(pathname string) (string, error) {
+	pathname = filepath.Clean(pathname)
+	suffix := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(pathname)
+		if err == nil {
+			return filepath.Join(resolved, suffix), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		_, err = os.Lstat(pathname)
+		if err == nil {
+			return "", errPathRejected
+		}
+		parent := filepath.Dir(pathname)
+		if parent == pathname {
+			return filepath.Join(pathname, suffix), nil
+		}
+		suffix = filepath.Join(filepath.Base(pathname), suffix)
+		pathname = parent
+	}
+}
+
+// filepath.Rel compares names without regard to case on windows
+func withinRoot(root, pathname string) bool {
+	rel, err := filepath.Rel(root, pathname)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel))
+}
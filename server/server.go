@@ -9,10 +9,12 @@ import (
 	"github.com/rstms/winexec/message"
 	"github.com/spf13/viper"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
 	"syscall"
@@ -25,16 +27,47 @@ const DEFAULT_BIND_ADDRESS = "127.0.0.1"
 const DEFAULT_HTTPS_PORT = 10080
 const DEFAULT_SHUTDOWN_TIMEOUT_SECONDS = 5
 const DEFAULT_AUTODELETE_INTERVAL_SECONDS = 60
+const DEFAULT_JOB_RETENTION_SECONDS = 3600
+const DEFAULT_JOB_OUTPUT_LIMIT = 16 * 1024 * 1024
+const DEFAULT_UPLOAD_SESSION_TIMEOUT_SECONDS = 86400
+const DEFAULT_ARCHIVE_MAX_BYTES = 4 * 1024 * 1024 * 1024
+const DEFAULT_ARCHIVE_MAX_FILES = 100000
 
 var Verbose bool
 var Debug bool
 
+// when TLSConfig is nil, the certificate, key and client CA are read from the
+// configured PEM files; when Listener is nil, the server listens on Address:Port;
+// when ReadOnlyRoots or ReadWriteRoots is set, request paths and process working
+// directories must be within those roots; when Policies is set, each client is
+// limited by the policy matching its certificate; when EnforceCommandRules is set,
+// processes are started only for commands allowed by CommandRules; when AuditLog
+// is set, an entry for each request is written to that file, and it is empty unless
+// the audit_log key is configured
 type WinexecServer struct {
 	Name                   string
 	Address                string
 	Version                string
 	Port                   int
-	started                chan struct{}
+	TLSConfig              *tls.Config
+	Listener               net.Listener
+	ReadOnlyRoots          []string
+	ReadWriteRoots         []string
+	Policies               []Policy
+	EnforceCommandRules    bool
+	CommandRules           []CommandRule
+	AuditLog               string
+	auditMaxBytes          int64
+	auditMaxFiles          int
+	auditRedact            []string
+	audit                  *auditLog
+	mux                    *http.ServeMux
+	handler                http.Handler
+	muxOnce                sync.Once
+	started                chan error
+	runLock                sync.Mutex
+	running                bool
+	stopping               chan struct{}
 	shutdownRequest        chan struct{}
 	shutdownComplete       chan struct{}
 	menu                   *Menu
@@ -51,6 +84,18 @@ type WinexecServer struct {
 	autoDeleteIntervalSeconds int
 	autoDeleteStopRequest     chan struct{}
 
+	jobs                map[string]*job
+	jobsLock            sync.Mutex
+	jobRetentionSeconds int
+	jobOutputLimit      int
+
+	uploads              map[string]*uploadSession
+	uploadsLock          sync.Mutex
+	uploadTimeoutSeconds int
+
+	archiveMaxBytes int64
+	archiveMaxFiles int
+
 	startupCommand      string
 	startupCommandArgs  []string
 	shutdownCommand     string
@@ -79,13 +124,29 @@ func NewWinexecServer() (*WinexecServer, error) {
 	ViperSetDefault(prefix+"key", filepath.Join(configDir, "winexec-server-key.pem"))
 	ViperSetDefault(prefix+"shutdown_timeout_seconds", DEFAULT_SHUTDOWN_TIMEOUT_SECONDS)
 	ViperSetDefault(prefix+"autodelete_interval_seconds", DEFAULT_AUTODELETE_INTERVAL_SECONDS)
+	ViperSetDefault(prefix+"job_retention_seconds", DEFAULT_JOB_RETENTION_SECONDS)
+	ViperSetDefault(prefix+"job_output_limit", DEFAULT_JOB_OUTPUT_LIMIT)
+	ViperSetDefault(prefix+"upload_session_timeout_seconds", DEFAULT_UPLOAD_SESSION_TIMEOUT_SECONDS)
+	ViperSetDefault(prefix+"archive_max_bytes", DEFAULT_ARCHIVE_MAX_BYTES)
+	ViperSetDefault(prefix+"archive_max_files", DEFAULT_ARCHIVE_MAX_FILES)
+	// auditing is enabled by setting audit_log to a file path
+	ViperSetDefault(prefix+"audit_log", "")
+	ViperSetDefault(prefix+"audit_max_bytes", DEFAULT_AUDIT_MAX_BYTES)
+	ViperSetDefault(prefix+"audit_max_files", DEFAULT_AUDIT_MAX_FILES)
 
 	s := WinexecServer{
 		Name:                      "winexec",
 		Address:                   ViperGetString(prefix + "bind_address"),
 		Port:                      ViperGetInt(prefix + "https_port"),
+		ReadOnlyRoots:             ViperGetStringSlice(prefix + "read_only_roots"),
+		ReadWriteRoots:            ViperGetStringSlice(prefix + "read_write_roots"),
+		EnforceCommandRules:       ViperGetBool(prefix + "enforce_command_rules"),
+		AuditLog:                  ViperGetString(prefix + "audit_log"),
+		auditMaxBytes:             ViperGetInt64(prefix + "audit_max_bytes"),
+		auditMaxFiles:             ViperGetInt(prefix + "audit_max_files"),
+		auditRedact:               ViperGetStringSlice(prefix + "audit_redact"),
 		Version:                   Version,
-		started:                   make(chan struct{}),
+		started:                   make(chan error),
 		shutdownRequest:           make(chan struct{}),
 		shutdownComplete:          make(chan struct{}),
 		debug:                     ViperGetBool(prefix + "debug"),
@@ -97,12 +158,37 @@ func NewWinexecServer() (*WinexecServer, error) {
 		autoDeleteIntervalSeconds: ViperGetInt(prefix + "autodelete_interval_seconds"),
 		autoDeleteFiles:           make(map[string]time.Time),
 		autoDeleteStopRequest:     make(chan struct{}),
+		jobs:                      make(map[string]*job),
+		jobRetentionSeconds:       ViperGetInt(prefix + "job_retention_seconds"),
+		jobOutputLimit:            ViperGetInt(prefix + "job_output_limit"),
+		uploads:                   make(map[string]*uploadSession),
+		uploadTimeoutSeconds:      ViperGetInt(prefix + "upload_session_timeout_seconds"),
+		archiveMaxBytes:           ViperGetInt64(prefix + "archive_max_bytes"),
+		archiveMaxFiles:           ViperGetInt(prefix + "archive_max_files"),
 		enableMenu:                ViperGetBool(prefix + "menu"),
 		startupCommand:            ViperGetString(prefix + "startup_command"),
 		startupCommandArgs:        ViperGetStringSlice(prefix + "startup_command_args"),
 		shutdownCommand:           ViperGetString(prefix + "shutdown_command"),
 		shutdownCommandArgs:       ViperGetStringSlice(prefix + "shutdown_command_args"),
 	}
+	err = viper.UnmarshalKey(ViperKey(prefix+"policies"), &s.Policies)
+	if err != nil {
+		return nil, Fatalf("failed reading policies: %v", err)
+	}
+	err = viper.UnmarshalKey(ViperKey(prefix+"command_rules"), &s.CommandRules)
+	if err != nil {
+		return nil, Fatalf("failed reading command rules: %v", err)
+	}
+	err = validateCommandRules(s.CommandRules)
+	if err != nil {
+		return nil, err
+	}
+	for _, pattern := range s.auditRedact {
+		_, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, Fatalf("audit redact pattern '%s': %v", pattern, err)
+		}
+	}
 	Verbose = s.verbose
 	Debug = s.debug
 	if Debug {
@@ -126,8 +212,15 @@ func (s *WinexecServer) Start() error {
 	log.Println("callingRunServer")
 	go runServer(s)
 	log.Println("awaiting 'started' message...")
-	<-s.started
+	err := <-s.started
 	log.Println("received 'started' message")
+	if err != nil {
+		return err
+	}
+	s.runLock.Lock()
+	s.running = true
+	s.stopping = make(chan struct{})
+	s.runLock.Unlock()
 	if s.enableMenu {
 		title := fmt.Sprintf("%s v%s", s.Name, s.Version)
 		menu, err := NewMenu(title, s.shutdownRequest, s.shutdownComplete)
@@ -139,9 +232,23 @@ func (s *WinexecServer) Start() error {
 	return nil
 }
 
+// a server that was not started, or is already shutting down, as when its menu
+// exits, is not stopped again
 func (s *WinexecServer) Stop() error {
+	s.runLock.Lock()
+	running := s.running
+	stopping := s.stopping
+	s.runLock.Unlock()
+	if !running {
+		return nil
+	}
 	log.Println("Stop: sending 'shutdownRequest' message")
-	s.shutdownRequest <- struct{}{}
+	select {
+	case s.shutdownRequest <- struct{}{}:
+	case <-stopping:
+		log.Println("Stop: server is already shutting down")
+		return nil
+	}
 	log.Println("Stop: awaiting 'shutdownComplete' message...")
 	<-s.shutdownComplete
 	log.Println("Stop: received 'shutdownComplete' message")
@@ -156,9 +263,9 @@ func (s *WinexecServer) Run() error {
 	if ViperGetBool("verbose") {
 		log.Println("CTRL-C to exit")
 	}
-	sigint := make(chan os.Signal)
+	sigint := make(chan os.Signal, 1)
 	signal.Notify(sigint, syscall.SIGINT)
-	sigterm := make(chan os.Signal)
+	sigterm := make(chan os.Signal, 1)
 	signal.Notify(sigterm, syscall.SIGTERM)
 	select {
 	case <-sigint:
@@ -187,6 +294,7 @@ func fail(w http.ResponseWriter, r *http.Request, failMessage string, status int
 	if Verbose {
 		log.Printf("%s <- winexec fail [%d] %s\n", r.RemoteAddr, status, failMessage)
 	}
+	auditResult(r, failMessage)
 	w.WriteHeader(status)
 	err := json.NewEncoder(w).Encode(&response)
 	if err != nil {
@@ -196,7 +304,7 @@ func fail(w http.ResponseWriter, r *http.Request, failMessage string, status int
 
 func succeed(w http.ResponseWriter, r *http.Request, response interface{}) {
 	if Verbose {
-		log.Printf("%s <- winexec response [200] %+v\n", r.RemoteAddr, response)
+		log.Printf("%s <- winexec response [200] %s\n", r.RemoteAddr, message.Redacted(response))
 	}
 	w.Header().Set("Content-Type", "application/json")
 	err := json.NewEncoder(w).Encode(response)
@@ -205,39 +313,32 @@ func succeed(w http.ResponseWriter, r *http.Request, response interface{}) {
 	}
 }
 
-func runServer(s *WinexecServer) {
-
-	if s.startupCommand != "" {
-		err := s.runCommand("startup", s.startupCommand, s.startupCommandArgs...)
-		if err != nil {
-			Warning("startup command failed")
-		}
-	}
-
+// read the server certificate, key and client CA from the configured PEM files
+func (s *WinexecServer) loadTLSConfig() (*tls.Config, error) {
 	serverCertPEM, err := os.ReadFile(s.cert)
 	if err != nil {
-		log.Fatalf("Failed reading server certificate: %v", err)
+		return nil, Fatalf("Failed reading server certificate: %v", err)
 	}
 
 	serverKeyPEM, err := os.ReadFile(s.key)
 	if err != nil {
-		log.Fatalf("Failed reading server certificate key: %v", err)
+		return nil, Fatalf("Failed reading server certificate key: %v", err)
 	}
 
 	serverCert, err := tls.X509KeyPair(serverCertPEM, serverKeyPEM)
 	if err != nil {
-		log.Fatalf("Failed creating X509 keypair: %v", err)
+		return nil, Fatalf("Failed creating X509 keypair: %v", err)
 	}
 
 	caCertPEM, err := os.ReadFile(s.ca)
 	if err != nil {
-		log.Fatalf("Failed reading CA file: %v", err)
+		return nil, Fatalf("Failed reading CA file: %v", err)
 	}
 
 	caCertPool := x509.NewCertPool()
 	ok := caCertPool.AppendCertsFromPEM(caCertPEM)
 	if !ok {
-		log.Fatalf("failed appending CA cert to pool")
+		return nil, Fatalf("failed appending CA cert to pool")
 	}
 
 	tlsConfig := &tls.Config{
@@ -245,30 +346,104 @@ func runServer(s *WinexecServer) {
 		ClientAuth:   tls.RequireAndVerifyClientCert,
 		ClientCAs:    caCertPool,
 	}
+	return tlsConfig, nil
+}
+
+// Handler returns the server's routes, which may be mounted on any http.Server
+// such as httptest.NewTLSServer; each WinexecServer has its own ServeMux
+func (s *WinexecServer) Handler() http.Handler {
+	s.muxOnce.Do(func() {
+		s.mux = http.NewServeMux()
+		s.mux.HandleFunc("GET /ping/", handlePing)
+		s.mux.HandleFunc("GET /os/", handleGetOS)
+		s.mux.HandleFunc("POST /exec/", handleExec)
+		s.mux.HandleFunc("POST /exec/stream/", handleExecStream)
+		s.mux.HandleFunc("POST /exec/check/", handleExecCheck)
+		s.mux.HandleFunc("POST /shell/", handleShell)
+		s.mux.HandleFunc("POST /spawn/", s.handleSpawn)
+		s.mux.HandleFunc("POST /job/start/", s.handleJobStart)
+		s.mux.HandleFunc("POST /job/status/", s.handleJobStatus)
+		s.mux.HandleFunc("POST /job/wait/", s.handleJobWait)
+		s.mux.HandleFunc("POST /job/kill/", s.handleJobKill)
+		s.mux.HandleFunc("POST /job/output/", s.handleJobOutput)
+		s.mux.HandleFunc("GET /jobs/", s.handleJobList)
+		s.mux.HandleFunc("POST /download/", handleFileDownload)
+		s.mux.HandleFunc("POST /upload/", handleFileUpload)
+		s.mux.HandleFunc("POST /download/stream/", handleFileDownloadStream)
+		s.mux.HandleFunc("POST /upload/stream/", handleFileUploadStream)
+		s.mux.HandleFunc("POST /upload/session/", s.handleUploadSession)
+		s.mux.HandleFunc("POST /upload/chunk/", s.handleUploadChunk)
+		s.mux.HandleFunc("POST /upload/status/", s.handleUploadStatus)
+		s.mux.HandleFunc("POST /upload/commit/", s.handleUploadCommit)
+		s.mux.HandleFunc("POST /upload/abort/", s.handleUploadAbort)
+		s.mux.HandleFunc("POST /delete/", handleFileDelete)
+		s.mux.HandleFunc("POST /rename/", handleFileRename)
+		s.mux.HandleFunc("POST /copy/", handleFileCopy)
+		s.mux.HandleFunc("POST /open/", handleFileOpen)
+		s.mux.HandleFunc("POST /write/", handleFileWrite)
+		s.mux.HandleFunc("POST /truncate/", handleFileTruncate)
+		s.mux.HandleFunc("POST /chmod/", handleFileChmod)
+		s.mux.HandleFunc("POST /chown/", handleFileChown)
+		s.mux.HandleFunc("POST /chtimes/", handleFileChtimes)
+		s.mux.HandleFunc("POST /remove/", handleFileRemove)
+		s.mux.HandleFunc("POST /dir/", handleDirectoryEntries)
+		s.mux.HandleFunc("POST /mkdir/", handleDirectoryCreate)
+		s.mux.HandleFunc("POST /rmdir/", handleDirectoryDestroy)
+		s.mux.HandleFunc("POST /get/", s.handleFileGet)
+		s.mux.HandleFunc("POST /isfile/", s.handleIsFile)
+		s.mux.HandleFunc("POST /isdir/", s.handleIsDir)
+		s.mux.HandleFunc("POST /hash/", handleHash)
+		s.mux.HandleFunc("POST /stat/", handleStat)
+		s.mux.HandleFunc("POST /walk/", handleWalk)
+		s.mux.HandleFunc("POST /archive/extract/", s.handleArchiveExtract)
+		s.mux.HandleFunc("POST /archive/pack/", handleArchivePack)
+		s.mux.HandleFunc("POST /audit/", s.handleAudit)
+		handler := withCommandRules(s.mux, newCommandRules(s.EnforceCommandRules, s.CommandRules))
+		handler = withPolicies(handler, s.Policies)
+		handler = withSandbox(handler, newSandbox(s.ReadOnlyRoots, s.ReadWriteRoots))
+		s.audit = newAuditLog(s.AuditLog, s.auditMaxBytes, s.auditMaxFiles, s.auditRedact)
+		s.handler = withAudit(handler, s.audit)
+	})
+	return s.handler
+}
+
+func runServer(s *WinexecServer) {
+
+	if s.startupCommand != "" {
+		err := s.runCommand("startup", s.startupCommand, s.startupCommandArgs...)
+		if err != nil {
+			Warning("startup command failed")
+		}
+	}
+
+	tlsConfig := s.TLSConfig
+	if tlsConfig == nil {
+		var err error
+		tlsConfig, err = s.loadTLSConfig()
+		if err != nil {
+			s.started <- err
+			return
+		}
+	}
+
+	listener := s.Listener
+	if listener == nil {
+		var err error
+		listener, err = net.Listen("tcp", fmt.Sprintf("%s:%d", s.Address, s.Port))
+		if err != nil {
+			s.started <- Fatalf("Listen failed: %v", err)
+			return
+		}
+	}
 
-	listen := fmt.Sprintf("%s:%d", s.Address, s.Port)
 	server := http.Server{
-		Addr:      listen,
+		Handler:   s.Handler(),
 		TLSConfig: tlsConfig,
 	}
 
-	http.HandleFunc("GET /ping/", handlePing)
-	http.HandleFunc("GET /os/", handleGetOS)
-	http.HandleFunc("POST /exec/", handleExec)
-	http.HandleFunc("POST /spawn/", handleSpawn)
-	http.HandleFunc("POST /download/", handleFileDownload)
-	http.HandleFunc("POST /upload/", handleFileUpload)
-	http.HandleFunc("POST /delete/", handleFileDelete)
-	http.HandleFunc("POST /dir/", handleDirectoryEntries)
-	http.HandleFunc("POST /mkdir/", handleDirectoryCreate)
-	http.HandleFunc("POST /rmdir/", handleDirectoryDestroy)
-	http.HandleFunc("POST /get/", s.handleFileGet)
-	http.HandleFunc("POST /isfile/", s.handleIsFile)
-	http.HandleFunc("POST /isdir/", s.handleIsDir)
-
-	log.Printf("%s v%s server listening on %s in TLS mode\n", s.Name, s.Version, server.Addr)
+	log.Printf("%s v%s server listening on %s in TLS mode\n", s.Name, s.Version, listener.Addr())
 	go func() {
-		err := server.ListenAndServeTLS("", "")
+		err := server.ServeTLS(listener, "", "")
 		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("ServeTLS failed: %v", err)
 		}
@@ -279,7 +454,7 @@ func runServer(s *WinexecServer) {
 	if s.verbose {
 		log.Println("runServer: sending 'started'")
 	}
-	s.started <- struct{}{}
+	s.started <- nil
 	if s.verbose {
 		log.Println("runServer: sent 'started'")
 	}
@@ -301,14 +476,22 @@ func runServer(s *WinexecServer) {
 	if s.verbose {
 		log.Println("runServer: received 'shutdownRequest'")
 	}
+	// the request may come from Stop or the menu, so the server is marked stopped here
+	s.runLock.Lock()
+	s.running = false
+	close(s.stopping)
+	s.runLock.Unlock()
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.shutdownTimeoutSeconds)*time.Second)
 	defer cancel()
 
-	err = server.Shutdown(ctx)
+	err := server.Shutdown(ctx)
 	if err != nil {
 		log.Fatalln("Server Shutdown failed: ", err)
 	}
+	if s.audit != nil {
+		s.audit.close()
+	}
 
 	s.stopAutoDelete()
 
@@ -382,6 +565,8 @@ func (s *WinexecServer) runAutoDelete() {
 			return
 		case <-ticker.C:
 			s.checkAutoDelete(false)
+			s.checkJobs()
+			s.checkUploads()
 		}
 	}
 }
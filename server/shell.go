@@ -0,0 +1,170 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rstms/winexec/message"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// an interactive session is a full-duplex stream: ShellFrame lines are read from the
+// request body while ExecFrame lines are written to the response until the process exits
+func handleShell(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.ShellRequest
+	body, err := decodePreamble(r, &request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	command := request.Command
+	if command == "" {
+		command = defaultShell()
+	}
+	err = checkProcess(r, &command, request.Args, &request.Env, &request.ProcessOptions)
+	if err != nil {
+		failPath(w, r, err, "invalid process request")
+		return
+	}
+
+	// the process is killed if the client disconnects
+	p := newProcess(r.Context(), request.Env, request.ProcessOptions, command, request.Args...)
+	cmd := p.cmd
+
+	err = http.NewResponseController(w).EnableFullDuplex()
+	if err != nil && Debug {
+		log.Printf("EnableFullDuplex: %v\n", err)
+	}
+
+	var stdin io.WriteCloser
+	var tty *os.File
+	outputs := map[string]io.Reader{}
+	if request.Term != "" && terminalSupported {
+		tty, err = startTerminal(cmd, request.Term, request.Rows, request.Cols)
+		if err != nil {
+			Warning("%v", Fatal(err))
+			fail(w, r, "shell failed", http.StatusBadRequest)
+			return
+		}
+		defer tty.Close()
+		stdin = tty
+		outputs[message.EXEC_STDOUT] = tty
+	} else {
+		stdin, err = cmd.StdinPipe()
+		if err != nil {
+			Warning("%v", Fatal(err))
+			fail(w, r, "shell failed", http.StatusInternalServerError)
+			return
+		}
+		outputs[message.EXEC_STDOUT], err = cmd.StdoutPipe()
+		if err != nil {
+			Warning("%v", Fatal(err))
+			fail(w, r, "shell failed", http.StatusInternalServerError)
+			return
+		}
+		outputs[message.EXEC_STDERR], err = cmd.StderrPipe()
+		if err != nil {
+			Warning("%v", Fatal(err))
+			fail(w, r, "shell failed", http.StatusInternalServerError)
+			return
+		}
+		err = cmd.Start()
+		if err != nil {
+			Warning("%v", Fatal(err))
+			fail(w, r, "shell failed", http.StatusBadRequest)
+			return
+		}
+	}
+	if Debug {
+		log.Printf("Shell: %v tty=%v\n", cmd, tty != nil)
+	}
+
+	go readShellFrames(body, stdin, tty)
+
+	frames := make(chan message.ExecFrame)
+	var readers sync.WaitGroup
+	readers.Add(len(outputs))
+	for name, pipe := range outputs {
+		go readFrames(pipe, name, frames, &readers)
+	}
+	go func() {
+		readers.Wait()
+		close(frames)
+	}()
+	if tty != nil {
+		// a background process holding the terminal open must not outlive the session
+		go func() {
+			<-r.Context().Done()
+			tty.Close()
+		}()
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	stream := newFrameWriter(w)
+	stream.Flush()
+	for frame := range frames {
+		stream.Write(&frame)
+	}
+
+	exitCode, timedOut, err := p.Wait()
+	if err != nil {
+		Warning("%v", Fatal(err))
+		auditExitCode(r, exitCode)
+		stream.Write(&message.ExecFrame{Stream: message.EXEC_ERROR, ExitCode: exitCode, Message: err.Error()})
+		return
+	}
+	if Debug {
+		log.Printf("exitCode=%d\n", exitCode)
+	}
+	auditExitCode(r, exitCode)
+	stream.Write(&message.ExecFrame{Stream: message.EXEC_EXIT, ExitCode: exitCode, TimedOut: timedOut, Message: fmt.Sprintf("%v", cmd)})
+	if Verbose {
+		log.Printf("%s <- winexec shell exit [%d] %d bytes\n", r.RemoteAddr, exitCode, stream.count)
+	}
+}
+
+// copy input frames to the process; a pipe is closed when the client ends its input,
+// while a terminal stays open until the process exits
+func readShellFrames(body io.Reader, stdin io.WriteCloser, tty *os.File) {
+	if tty == nil {
+		defer stdin.Close()
+	}
+	decoder := json.NewDecoder(body)
+	for {
+		var frame message.ShellFrame
+		err := decoder.Decode(&frame)
+		if err != nil {
+			if err != io.EOF && Debug {
+				log.Printf("shell input: %v\n", err)
+			}
+			return
+		}
+		switch frame.Stream {
+		case message.SHELL_STDIN:
+			_, err = stdin.Write(frame.Data)
+		case message.SHELL_RESIZE:
+			if tty != nil {
+				err = resizeTerminal(tty, frame.Rows, frame.Cols)
+			}
+		default:
+			err = fmt.Errorf("unexpected shell frame: %s", message.Redacted(frame))
+		}
+		if err != nil {
+			if Debug {
+				log.Printf("shell input: %v\n", err)
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,35 @@
+//go:build !windows
+
+package server
+
+import (
+	"github.com/creack/pty"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+const terminalSupported = true
+
+func defaultShell() string {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		return "/bin/sh"
+	}
+	return shell
+}
+
+// start the command on a new pseudo-terminal; the session leader is also the
+// process group leader, so the process tree can still be signaled as a group
+func startTerminal(cmd *exec.Cmd, term string, rows, cols int) (*os.File, error) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	cmd.Env = append(cmd.Environ(), "TERM="+term)
+	if rows == 0 || cols == 0 {
+		return pty.Start(cmd)
+	}
+	return pty.StartWithSize(cmd, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
+
+func resizeTerminal(tty *os.File, rows, cols int) error {
+	return pty.Setsize(tty, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
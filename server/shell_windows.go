@@ -0,0 +1,28 @@
+//go:build windows
+
+package server
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// sessions use pipes, so console programs see no terminal and window size changes are ignored
+const terminalSupported = false
+
+func defaultShell() string {
+	shell := os.Getenv("COMSPEC")
+	if shell == "" {
+		return "cmd.exe"
+	}
+	return shell
+}
+
+func startTerminal(cmd *exec.Cmd, term string, rows, cols int) (*os.File, error) {
+	return nil, errors.New("terminal not supported")
+}
+
+func resizeTerminal(tty *os.File, rows, cols int) error {
+	return nil
+}
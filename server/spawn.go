@@ -1,18 +1,19 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"github.com/rstms/winexec/message"
 	"log"
 	"net/http"
-	"os"
-	"os/exec"
 	"runtime"
 	"strings"
 )
 
-func handleSpawn(w http.ResponseWriter, r *http.Request) {
+// characters cmd interprets outside quotes
+const CMD_METACHARACTERS = `()%!^"<>&|`
+
+func (s *WinexecServer) handleSpawn(w http.ResponseWriter, r *http.Request) {
 	if Verbose {
 		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
 	}
@@ -24,55 +25,100 @@ func handleSpawn(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if Verbose {
-		log.Printf("%+v\n", request)
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	err = checkProcess(r, &request.Command, request.Args, &request.Env, &request.ProcessOptions)
+	if err != nil {
+		failPath(w, r, err, "invalid process request")
+		return
 	}
 
-	spawned, exitCode, err := spawn(request.Env, request.Command, request.Args)
+	j, err := s.spawn(&request, jobOwner(r))
 	if err != nil {
 		Warning("spawn: %v", Fatal(err))
 		fail(w, r, "spawn failed", http.StatusBadRequest)
 		return
 	}
+	status := j.Status()
 	response := message.SpawnResponse{
-		Success:  true,
-		Message:  "spawned",
-		Command:  spawned,
-		ExitCode: exitCode,
+		Success: true,
+		Message: "spawned",
+		Command: status.Command,
+		JobID:   status.ID,
+		PID:     status.PID,
 	}
 	succeed(w, r, &response)
 }
 
-func spawn(env []string, command string, args []string) (string, int, error) {
-	var cmd *exec.Cmd
-	switch runtime.GOOS {
-	case "windows":
-		commandWords := append([]string{command}, args...)
-		commandLine := strings.Join(commandWords, " ")
-		cmd = exec.Command("cmd", "/c", "start "+commandLine)
-	default:
-		cmd = exec.Command(command, args...)
+// spawned processes are tracked as jobs, but their output is not captured
+func (s *WinexecServer) spawn(request *message.SpawnRequest, owner string) (*job, error) {
+	command := request.Command
+	args := request.Args
+	commandLine := ""
+	if runtime.GOOS == "windows" {
+		// start /wait keeps cmd running as the parent, so the job exit code
+		// is the command's and a timeout can kill the process tree
+		commandLine = "cmd /c " + startCommandLine(command, args)
+		command = "cmd"
+		args = nil
 	}
-	cmd.Stdin = nil
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-	var exitCode int
-	if len(env) > 0 {
-		cmd.Env = append(os.Environ(), env...)
+	p := newProcess(context.Background(), request.Env, request.ProcessOptions, command, args...)
+	if commandLine != "" {
+		setCommandLine(p.cmd, commandLine)
 	}
+	p.cmd.Stdin = nil
+	p.cmd.Stdout = nil
+	p.cmd.Stderr = nil
 	if Debug {
-		log.Printf("Spawn: %v\n", cmd)
+		log.Printf("Spawn: %v\n", p.cmd)
 	}
-	err := cmd.Start()
-	if err != nil {
-		switch e := err.(type) {
-		case *exec.ExitError:
-			exitCode = e.ExitCode()
-			err = nil
+	return s.startJob(p, true, owner)
+}
+
+// cmd metacharacters are escaped with a caret, including quotes, so cmd never enters a
+// quoted state and passes each word to start as quoted for the command's argv parser;
+// the empty title keeps start from taking a quoted command as the window title
+func startCommandLine(command string, args []string) string {
+	words := []string{"start", `""`, "/wait"}
+	for _, word := range append([]string{command}, args...) {
+		words = append(words, escapeCmd(quoteArg(word)))
+	}
+	return strings.Join(words, " ")
+}
+
+// quote an argument as syscall.EscapeArg does on windows
+func quoteArg(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"") {
+		return arg
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	backslashes := 0
+	for i := 0; i < len(arg); i++ {
+		switch arg[i] {
+		case '\\':
+			backslashes++
+			continue
+		case '"':
+			b.WriteString(strings.Repeat(`\`, 2*backslashes+1))
+		default:
+			b.WriteString(strings.Repeat(`\`, backslashes))
 		}
+		backslashes = 0
+		b.WriteByte(arg[i])
 	}
-	if Debug {
-		log.Printf("exitCode=%d\n", exitCode)
-		log.Printf("err=%v\n", err)
+	b.WriteString(strings.Repeat(`\`, 2*backslashes))
+	b.WriteByte('"')
+	return b.String()
+}
+
+func escapeCmd(word string) string {
+	var b strings.Builder
+	for _, c := range word {
+		if strings.ContainsRune(CMD_METACHARACTERS, c) {
+			b.WriteByte('^')
+		}
+		b.WriteRune(c)
 	}
-	return fmt.Sprintf("%v", cmd), exitCode, err
+	return b.String()
 }
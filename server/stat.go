@@ -0,0 +1,71 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/rstms/winexec/message"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+)
+
+// return the stat data for a file; the link target is set when info comes from Lstat
+func fileStat(pathname string, info fs.FileInfo) message.FileStat {
+	stat := message.FileStat{
+		Name:    info.Name(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Mode:    info.Mode(),
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(pathname)
+		if err != nil {
+			Warning("%v", Fatal(err))
+		}
+		stat.LinkTarget = target
+	}
+	statSys(pathname, info, &stat)
+	return stat
+}
+
+func handleStat(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.StatRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	pathname, err := readPath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	stat := os.Lstat
+	if request.Follow {
+		stat = os.Stat
+	}
+	info, err := stat(pathname)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		if os.IsNotExist(err) {
+			fail(w, r, "not found", http.StatusNotFound)
+			return
+		}
+		fail(w, r, "stat failed", http.StatusBadRequest)
+		return
+	}
+	response := message.StatResponse{
+		Success:  true,
+		Message:  "stat",
+		Pathname: pathname,
+		Stat:     fileStat(pathname, info),
+	}
+	succeed(w, r, &response)
+}
@@ -0,0 +1,10 @@
+package server
+
+import (
+	"syscall"
+	"time"
+)
+
+func statTimes(sys *syscall.Stat_t) (time.Time, time.Time) {
+	return time.Unix(int64(sys.Atimespec.Sec), int64(sys.Atimespec.Nsec)), time.Unix(int64(sys.Ctimespec.Sec), int64(sys.Ctimespec.Nsec))
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !openbsd && !freebsd && !windows
+
+package server
+
+import (
+	"github.com/rstms/winexec/message"
+	"io/fs"
+	"strings"
+)
+
+func statSys(pathname string, info fs.FileInfo, stat *message.FileStat) {
+	stat.ReadOnly = info.Mode().Perm()&0222 == 0
+	stat.Hidden = strings.HasPrefix(info.Name(), ".")
+}
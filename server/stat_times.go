@@ -0,0 +1,12 @@
+//go:build linux || openbsd
+
+package server
+
+import (
+	"syscall"
+	"time"
+)
+
+func statTimes(sys *syscall.Stat_t) (time.Time, time.Time) {
+	return time.Unix(int64(sys.Atim.Sec), int64(sys.Atim.Nsec)), time.Unix(int64(sys.Ctim.Sec), int64(sys.Ctim.Nsec))
+}
@@ -0,0 +1,34 @@
+//go:build linux || openbsd || freebsd
+
+package server
+
+import (
+	"github.com/rstms/winexec/message"
+	"io/fs"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+func statSys(pathname string, info fs.FileInfo, stat *message.FileStat) {
+	stat.ReadOnly = info.Mode().Perm()&0222 == 0
+	stat.Hidden = strings.HasPrefix(info.Name(), ".")
+	sys, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	stat.AccessTime, stat.ChangeTime = statTimes(sys)
+	uid := strconv.FormatUint(uint64(sys.Uid), 10)
+	stat.Owner = uid
+	owner, err := user.LookupId(uid)
+	if err == nil {
+		stat.Owner = owner.Username
+	}
+	gid := strconv.FormatUint(uint64(sys.Gid), 10)
+	stat.Group = gid
+	group, err := user.LookupGroupId(gid)
+	if err == nil {
+		stat.Group = group.Name
+	}
+}
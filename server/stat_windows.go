@@ -0,0 +1,44 @@
+package server
+
+import (
+	"github.com/rstms/winexec/message"
+	"golang.org/x/sys/windows"
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+func statSys(pathname string, info fs.FileInfo, stat *message.FileStat) {
+	sys, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return
+	}
+	stat.AccessTime = time.Unix(0, sys.LastAccessTime.Nanoseconds())
+	stat.CreateTime = time.Unix(0, sys.CreationTime.Nanoseconds())
+	stat.ReadOnly = sys.FileAttributes&windows.FILE_ATTRIBUTE_READONLY != 0
+	stat.Hidden = sys.FileAttributes&windows.FILE_ATTRIBUTE_HIDDEN != 0
+	descriptor, err := windows.GetNamedSecurityInfo(pathname, windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION|windows.GROUP_SECURITY_INFORMATION)
+	if err != nil {
+		return
+	}
+	owner, _, err := descriptor.Owner()
+	if err == nil && owner != nil {
+		stat.Owner = accountName(owner)
+	}
+	group, _, err := descriptor.Group()
+	if err == nil && group != nil {
+		stat.Group = accountName(group)
+	}
+}
+
+// return DOMAIN\account for a SID, or the SID string if it cannot be resolved
+func accountName(sid *windows.SID) string {
+	account, domain, _, err := sid.LookupAccount("")
+	if err != nil {
+		return sid.String()
+	}
+	if domain != "" {
+		return domain + `\` + account
+	}
+	return account
+}
@@ -0,0 +1,308 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/rstms/winexec/checksum"
+	"github.com/rstms/winexec/message"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// committed sessions are kept this long so a repeated commit gets the same response
+const UPLOAD_COMMIT_RETENTION_SECONDS = 300
+
+// an upload session writes chunks to a temporary file in the target directory,
+// which is renamed into place when the session is committed
+type uploadSession struct {
+	mutex        sync.Mutex
+	id           string
+	pathname     string
+	tempPathname string
+	size         int64
+	offset       int64
+	mode         os.FileMode
+	timestamp    time.Time
+	force        bool
+	sha256       string
+	sha512       string
+	lastActive   time.Time
+	committed    *message.UploadSessionResponse
+}
+
+func (u *uploadSession) response(status string) *message.UploadSessionResponse {
+	return &message.UploadSessionResponse{
+		Success:   true,
+		Message:   status,
+		SessionID: u.id,
+		Pathname:  u.pathname,
+		Size:      u.size,
+		Offset:    u.offset,
+	}
+}
+
+// write data at offset, truncating anything previously written past it
+func (u *uploadSession) write(offset int64, data io.Reader) error {
+	file, err := os.OpenFile(u.tempPathname, os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	err = file.Truncate(offset)
+	if err != nil {
+		return err
+	}
+	u.offset = offset
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	// one byte past the remaining size is read to detect oversized chunks
+	count, err := io.Copy(file, io.LimitReader(data, u.size-offset+1))
+	u.offset += count
+	if err != nil {
+		return err
+	}
+	if u.offset > u.size {
+		u.offset = u.size
+		err = file.Truncate(u.size)
+		if err != nil {
+			return err
+		}
+		return Fatalf("chunk exceeds upload size %d", u.size)
+	}
+	return nil
+}
+
+func (s *WinexecServer) getUpload(id string) (*uploadSession, bool) {
+	s.uploadsLock.Lock()
+	defer s.uploadsLock.Unlock()
+	u, ok := s.uploads[id]
+	return u, ok
+}
+
+func (s *WinexecServer) removeUpload(u *uploadSession) {
+	s.uploadsLock.Lock()
+	delete(s.uploads, u.id)
+	s.uploadsLock.Unlock()
+	err := os.Remove(u.tempPathname)
+	if err != nil && !os.IsNotExist(err) {
+		Warning("failed removing upload file: %v", err)
+	}
+}
+
+// discard upload sessions that have been idle longer than the session timeout, and
+// committed sessions after the commit retention time
+func (s *WinexecServer) checkUploads() {
+	expired := []*uploadSession{}
+	idleLimit := time.Now().Add(-time.Duration(s.uploadTimeoutSeconds) * time.Second)
+	commitLimit := time.Now().Add(-UPLOAD_COMMIT_RETENTION_SECONDS * time.Second)
+	s.uploadsLock.Lock()
+	for _, u := range s.uploads {
+		if u.mutex.TryLock() {
+			if u.lastActive.Before(idleLimit) || (u.committed != nil && u.lastActive.Before(commitLimit)) {
+				expired = append(expired, u)
+			}
+			u.mutex.Unlock()
+		}
+	}
+	s.uploadsLock.Unlock()
+	for _, u := range expired {
+		if u.committed == nil {
+			log.Printf("discarding expired upload session %s: %s\n", u.id, u.pathname)
+		}
+		s.removeUpload(u)
+	}
+}
+
+func (s *WinexecServer) handleUploadSession(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.UploadSessionRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	pathname, err := writePath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	if IsFile(pathname) && !request.Force {
+		Warning("file exists: '%s'", pathname)
+		fail(w, r, "file exists", http.StatusBadRequest)
+		return
+	}
+	if failIfDir(pathname, w, r) {
+		return
+	}
+	if request.Size < 0 {
+		fail(w, r, "invalid size", http.StatusBadRequest)
+		return
+	}
+	id, err := newID()
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "session create failed", http.StatusInternalServerError)
+		return
+	}
+	dir, name := filepath.Split(pathname)
+	tempPathname := filepath.Join(dir, "."+name+".winexec-upload-"+id)
+	file, err := os.OpenFile(tempPathname, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "session create failed", http.StatusBadRequest)
+		return
+	}
+	err = file.Close()
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "session create failed", http.StatusInternalServerError)
+		return
+	}
+	u := uploadSession{
+		id:           id,
+		pathname:     pathname,
+		tempPathname: tempPathname,
+		size:         request.Size,
+		mode:         request.Mode,
+		timestamp:    request.Timestamp,
+		force:        request.Force,
+		sha256:       request.SHA256,
+		sha512:       request.SHA512,
+		lastActive:   time.Now(),
+	}
+	s.uploadsLock.Lock()
+	s.uploads[id] = &u
+	s.uploadsLock.Unlock()
+	succeed(w, r, u.response("session created"))
+}
+
+// decode an UploadRequest from the body preamble and lock the session, failing the request if it is not found
+func (s *WinexecServer) requestUpload(w http.ResponseWriter, r *http.Request) (*message.UploadRequest, *uploadSession, io.Reader, bool) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.UploadRequest
+	body, err := decodePreamble(r, &request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return nil, nil, nil, false
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	u, ok := s.getUpload(request.SessionID)
+	if !ok {
+		Warning("upload session not found: %s", request.SessionID)
+		fail(w, r, "upload session not found", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+	u.mutex.Lock()
+	u.lastActive = time.Now()
+	return &request, u, body, true
+}
+
+func (s *WinexecServer) handleUploadChunk(w http.ResponseWriter, r *http.Request) {
+	request, u, body, ok := s.requestUpload(w, r)
+	if !ok {
+		return
+	}
+	defer u.mutex.Unlock()
+	if u.committed != nil {
+		Warning("upload session committed: %s", u.id)
+		fail(w, r, "upload committed", http.StatusConflict)
+		return
+	}
+	if request.Offset < 0 || request.Offset > u.offset {
+		Warning("upload offset %d beyond confirmed offset %d", request.Offset, u.offset)
+		fail(w, r, "invalid offset", http.StatusConflict)
+		return
+	}
+	err := u.write(request.Offset, body)
+	u.lastActive = time.Now()
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "chunk write failed", http.StatusBadRequest)
+		return
+	}
+	succeed(w, r, u.response("chunk written"))
+}
+
+func (s *WinexecServer) handleUploadStatus(w http.ResponseWriter, r *http.Request) {
+	_, u, _, ok := s.requestUpload(w, r)
+	if !ok {
+		return
+	}
+	defer u.mutex.Unlock()
+	succeed(w, r, u.response("status"))
+}
+
+func (s *WinexecServer) handleUploadCommit(w http.ResponseWriter, r *http.Request) {
+	_, u, _, ok := s.requestUpload(w, r)
+	if !ok {
+		return
+	}
+	defer u.mutex.Unlock()
+	// a commit repeated after a lost response succeeds again
+	if u.committed != nil {
+		succeed(w, r, u.committed)
+		return
+	}
+	if u.offset != u.size {
+		Warning("upload incomplete: %d of %d bytes", u.offset, u.size)
+		fail(w, r, "upload incomplete", http.StatusConflict)
+		return
+	}
+	if IsFile(u.pathname) && !u.force {
+		Warning("file exists: '%s'", u.pathname)
+		fail(w, r, "file exists", http.StatusBadRequest)
+		return
+	}
+	hasher, err := checksum.File(u.tempPathname)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "commit failed", http.StatusInternalServerError)
+		return
+	}
+	// the session is discarded on a mismatch since its content cannot be trusted
+	err = hasher.VerifyAll(u.sha256, u.sha512)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		s.removeUpload(u)
+		fail(w, r, "checksum mismatch", http.StatusUnprocessableEntity)
+		return
+	}
+	err = finishFile(u.tempPathname, u.pathname, u.mode, u.timestamp)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "commit failed", http.StatusBadRequest)
+		return
+	}
+	response := u.response("uploaded")
+	response.SHA256 = hasher.Sum(checksum.SHA256)
+	response.SHA512 = hasher.Sum(checksum.SHA512)
+	u.committed = response
+	succeed(w, r, response)
+}
+
+func (s *WinexecServer) handleUploadAbort(w http.ResponseWriter, r *http.Request) {
+	_, u, _, ok := s.requestUpload(w, r)
+	if !ok {
+		return
+	}
+	defer u.mutex.Unlock()
+	s.removeUpload(u)
+	succeed(w, r, u.response("aborted"))
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/rstms/winexec/message"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// walk frames are flushed to the client in batches
+const WALK_FLUSH_COUNT = 100
+
+func handleWalk(w http.ResponseWriter, r *http.Request) {
+	if Verbose {
+		log.Printf("%s -> winexec %s %s\n", r.RemoteAddr, r.Method, r.URL.Path)
+	}
+	var request message.WalkRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		fail(w, r, "failed decoding request", http.StatusBadRequest)
+		return
+	}
+	if Verbose {
+		log.Printf("%s\n", message.Redacted(request))
+	}
+	for _, pattern := range append(request.Include, request.Exclude...) {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			Warning("bad pattern '%s': %v", pattern, err)
+			fail(w, r, "bad pattern", http.StatusBadRequest)
+			return
+		}
+	}
+	root, err := readPath(r, request.Pathname)
+	if err != nil {
+		failPath(w, r, err, "invalid path")
+		return
+	}
+	_, err = os.Lstat(root)
+	if err != nil {
+		Warning("%v", Fatal(err))
+		if os.IsNotExist(err) {
+			fail(w, r, "not found", http.StatusNotFound)
+			return
+		}
+		fail(w, r, "stat failed", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	encoder := json.NewEncoder(w)
+	controller := http.NewResponseController(w)
+	count := 0
+	err = filepath.WalkDir(root, func(pathname string, entry fs.DirEntry, walkErr error) error {
+		if r.Context().Err() != nil {
+			return r.Context().Err()
+		}
+		relative, err := filepath.Rel(root, pathname)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return walkErr
+		}
+		relative = filepath.ToSlash(relative)
+		frame := message.WalkFrame{Path: relative}
+		if relative != "." && !walkSelect(&request.WalkOptions, relative, entry) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			frame.Error = err.Error()
+		} else {
+			frame.Stat = fileStat(pathname, info)
+		}
+		if walkErr != nil {
+			frame.Error = walkErr.Error()
+		}
+		err = encoder.Encode(&frame)
+		if err != nil {
+			return err
+		}
+		count++
+		if count%WALK_FLUSH_COUNT == 0 {
+			controller.Flush()
+		}
+		if entry.IsDir() && walkErr == nil && request.MaxDepth > 0 && walkDepth(relative) >= request.MaxDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		Warning("walk failed: %v", err)
+		encoder.Encode(&message.WalkFrame{Error: err.Error()})
+		return
+	}
+	encoder.Encode(&message.WalkFrame{Done: true})
+	if Verbose {
+		log.Printf("%s <- winexec walk [200] %s %d entries\n", r.RemoteAddr, root, count)
+	}
+}
+
+func walkDepth(relative string) int {
+	if relative == "." {
+		return 0
+	}
+	return strings.Count(relative, "/") + 1
+}
+
+func walkMatch(patterns []string, relative string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, relative); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, path.Base(relative)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// return true if the entry is selected by the walk options
+func walkSelect(options *message.WalkOptions, relative string, entry fs.DirEntry) bool {
+	if options.MaxDepth > 0 && walkDepth(relative) > options.MaxDepth {
+		return false
+	}
+	if walkMatch(options.Exclude, relative) {
+		return false
+	}
+	if entry.IsDir() {
+		return true
+	}
+	if len(options.Include) > 0 && !walkMatch(options.Include, relative) {
+		return false
+	}
+	if options.MinSize == 0 && options.MaxSize == 0 && options.ModifiedAfter.IsZero() && options.ModifiedBefore.IsZero() {
+		return true
+	}
+	info, err := entry.Info()
+	if err != nil {
+		return true
+	}
+	if info.Size() < options.MinSize || (options.MaxSize > 0 && info.Size() > options.MaxSize) {
+		return false
+	}
+	if !options.ModifiedAfter.IsZero() && !info.ModTime().After(options.ModifiedAfter) {
+		return false
+	}
+	if !options.ModifiedBefore.IsZero() && !info.ModTime().Before(options.ModifiedBefore) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,138 @@
+package wintest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"time"
+)
+
+const CERT_LIFETIME = 24 * time.Hour
+
+// Certs is an ephemeral certificate authority with a server certificate for the
+// loopback address; nothing is written to disk unless the caller writes the PEM data
+type Certs struct {
+	CA     *x509.Certificate
+	CAPEM  []byte
+	Server tls.Certificate
+	caKey  *ecdsa.PrivateKey
+}
+
+// ClientCert is a client certificate issued by the ephemeral CA
+type ClientCert struct {
+	Certificate *x509.Certificate
+	CertPEM     []byte
+	KeyPEM      []byte
+}
+
+func NewCerts() (*Certs, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	template := newTemplate("winexec test CA")
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage |= x509.KeyUsageCertSign
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	c := Certs{
+		CA:    ca,
+		CAPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		caKey: caKey,
+	}
+	template = newTemplate("winexec test server")
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	template.IPAddresses = []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	template.DNSNames = []string{"localhost"}
+	certPEM, keyPEM, _, err := c.issue(template)
+	if err != nil {
+		return nil, err
+	}
+	c.Server, err = tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	return &c, nil
+}
+
+func newTemplate(commonName string) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(CERT_LIFETIME),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+}
+
+// sign a certificate for a new key, returning the certificate and key as PEM
+func (c *Certs) issue(template *x509.Certificate) ([]byte, []byte, *x509.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, nil, Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, c.CA, &key.PublicKey, c.caKey)
+	if err != nil {
+		return nil, nil, nil, Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, nil, Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, nil, Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, cert, nil
+}
+
+// issue a client certificate with the given subject common name and organizational units
+func (c *Certs) NewClientCert(commonName string, organizationalUnits ...string) (*ClientCert, error) {
+	template := newTemplate(commonName)
+	template.Subject.OrganizationalUnit = organizationalUnits
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	certPEM, keyPEM, cert, err := c.issue(template)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientCert{Certificate: cert, CertPEM: certPEM, KeyPEM: keyPEM}, nil
+}
+
+// the server requires a client certificate issued by the CA, as runServer does
+func (c *Certs) ServerTLSConfig() *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AddCert(c.CA)
+	return &tls.Config{
+		Certificates: []tls.Certificate{c.Server},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+}
+
+func (c *Certs) ClientTLSConfig(client *ClientCert) (*tls.Config, error) {
+	cert, err := tls.X509KeyPair(client.CertPEM, client.KeyPEM)
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(c.CA)
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+	}, nil
+}
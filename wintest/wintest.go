@@ -0,0 +1,182 @@
+// Package wintest runs a WinexecServer in the calling process with ephemeral
+// certificates so clients can be tested without a remote host
+package wintest
+
+import (
+	"fmt"
+	"github.com/rstms/winexec/client"
+	"github.com/rstms/winexec/server"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+var initOnce sync.Once
+
+// Harness is a server listening on a loopback port and a client connected to it;
+// Dir is an empty directory for the test's files, and the server's audit log is
+// written outside it; both are removed by Stop
+type Harness struct {
+	Server     *server.WinexecServer
+	Client     *client.WinexecClient
+	Certs      *Certs
+	ClientCert *ClientCert
+	URL        string
+	Dir        string
+	tmpDir     string
+}
+
+// an Option configures the server before it is started
+type Option func(h *Harness, s *server.WinexecServer)
+
+// restrict the server to paths under the test directory
+func Sandboxed(h *Harness, s *server.WinexecServer) {
+	s.ReadWriteRoots = []string{h.Dir}
+}
+
+// start a server and client; go-common is initialized on first use, and the client
+// URL is set in the process-wide config before the client is created
+func Start(options ...Option) (*Harness, error) {
+	initOnce.Do(func() {
+		Init("winexec_test", server.Version, "")
+	})
+	certs, err := NewCerts()
+	if err != nil {
+		return nil, err
+	}
+	tmpDir, err := os.MkdirTemp("", "wintest-")
+	if err != nil {
+		return nil, Fatal(err)
+	}
+	h := Harness{
+		Certs:  certs,
+		Dir:    filepath.Join(tmpDir, "sandbox"),
+		tmpDir: tmpDir,
+	}
+	err = h.start(options)
+	if err != nil {
+		h.Stop()
+		return nil, err
+	}
+	return &h, nil
+}
+
+func (h *Harness) start(options []Option) error {
+	err := os.Mkdir(h.Dir, 0700)
+	if err != nil {
+		return Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return Fatal(err)
+	}
+	s, err := server.NewWinexecServer()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	s.TLSConfig = h.Certs.ServerTLSConfig()
+	s.Listener = listener
+	s.AuditLog = filepath.Join(h.tmpDir, "audit.log")
+	for _, option := range options {
+		option(h, s)
+	}
+	err = s.Start()
+	if err != nil {
+		listener.Close()
+		return err
+	}
+	h.Server = s
+	h.URL = fmt.Sprintf("https://%s", listener.Addr())
+
+	// the client reads its URL from the config and its certificates from files
+	h.ClientCert, err = h.Certs.NewClientCert("winexec test client")
+	if err != nil {
+		return err
+	}
+	h.Client, err = h.NewClient(h.ClientCert)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// create another client presenting clientCert; the caller must close it
+func (h *Harness) NewClient(clientCert *ClientCert) (*client.WinexecClient, error) {
+	ca, cert, key, err := h.WriteClientFiles(clientCert)
+	if err != nil {
+		return nil, err
+	}
+	ViperSet("winexec.client.url", h.URL)
+	return client.NewWinexecClient(ca, cert, key)
+}
+
+// New starts a harness that is stopped when the test ends
+func New(t testing.TB, options ...Option) *Harness {
+	t.Helper()
+	h, err := Start(options...)
+	if err != nil {
+		t.Fatalf("wintest: %v", err)
+	}
+	t.Cleanup(func() {
+		err := h.Stop()
+		if err != nil {
+			t.Errorf("wintest: %v", err)
+		}
+	})
+	return h
+}
+
+// return a pathname in the test directory
+func (h *Harness) Path(elem ...string) string {
+	return filepath.Join(append([]string{h.Dir}, elem...)...)
+}
+
+// write the CA and a client certificate and key as PEM files, returning their pathnames
+func (h *Harness) WriteClientFiles(clientCert *ClientCert) (string, string, string, error) {
+	dir, err := os.MkdirTemp(h.tmpDir, "certs-")
+	if err != nil {
+		return "", "", "", Fatal(err)
+	}
+	ca := filepath.Join(dir, "ca.pem")
+	cert := filepath.Join(dir, "cert.pem")
+	key := filepath.Join(dir, "key.pem")
+	for pathname, data := range map[string][]byte{ca: h.Certs.CAPEM, cert: clientCert.CertPEM, key: clientCert.KeyPEM} {
+		err := os.WriteFile(pathname, data, 0600)
+		if err != nil {
+			return "", "", "", Fatal(err)
+		}
+	}
+	return ca, cert, key, nil
+}
+
+// return an http.Client presenting clientCert, for requests the WinexecClient does not make
+func (h *Harness) HTTPClient(clientCert *ClientCert) (*http.Client, error) {
+	config, err := h.Certs.ClientTLSConfig(clientCert)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}, nil
+}
+
+// stop the client and server and remove the test directory
+func (h *Harness) Stop() error {
+	var err error
+	if h.Client != nil {
+		err = h.Client.Close()
+	}
+	if h.Server != nil {
+		stopErr := h.Server.Stop()
+		if err == nil {
+			err = stopErr
+		}
+	}
+	removeErr := os.RemoveAll(h.tmpDir)
+	if err == nil && removeErr != nil {
+		err = Fatal(removeErr)
+	}
+	return err
+}
@@ -0,0 +1,20 @@
+package wintest
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestHarness(t *testing.T) {
+	h, err := Start()
+	require.Nil(t, err)
+	require.DirExists(t, h.Dir)
+	require.Nil(t, h.Client.Ping())
+	require.Nil(t, os.WriteFile(h.Path("file.txt"), []byte("data"), 0600))
+	isFile, err := h.Client.IsFile(h.Path("file.txt"))
+	require.Nil(t, err)
+	require.True(t, isFile)
+	require.Nil(t, h.Stop())
+	require.NoDirExists(t, h.Dir)
+}